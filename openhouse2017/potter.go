@@ -0,0 +1,619 @@
+package potter
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// bookPrice is the price of a single book in cents
+const bookPrice = 800
+
+// discounts maps the number of distinct titles in a group to the discount in percent
+var discounts = []int{0, 0, 5, 10, 20, 25}
+
+// DiscountMode says how the Discounts of a Pricer are applied
+type DiscountMode int
+
+const (
+	// Percentage means that Discounts[k] is the discount in percent of a group of k distinct titles
+	Percentage DiscountMode = iota
+	// FlatPerBook means that Discounts[k] is the number of cents subtracted from the price of each book in a group of k distinct titles
+	FlatPerBook
+)
+
+// Rounding says how a discounted price with a fraction of a cent is converted to whole cents
+type Rounding int
+
+const (
+	// TruncateDown drops the fraction of a cent, e.g. 406.5 becomes 406, it is the default
+	TruncateDown Rounding = iota
+	// RoundHalfUp rounds to the nearest cent and a half cent up, e.g. 406.5 becomes 407
+	RoundHalfUp
+	// RoundHalfToEven rounds to the nearest cent and a half cent to the even cent (banker's rounding), e.g. 406.5 becomes 406 and 407.5 becomes 408
+	RoundHalfToEven
+)
+
+// cents converts the price in cents with a fraction of a cent to whole cents
+func (r Rounding) cents(price float64) int {
+	// drop the noise of floating point arithmetic, e.g. 3599.9999999999995 is 3600
+	price = math.Round(price*1e6) / 1e6
+	switch r {
+	case RoundHalfUp:
+		return int(math.Floor(price + 0.5))
+	case RoundHalfToEven:
+		return int(math.RoundToEven(price))
+	default:
+		return int(math.Floor(price))
+	}
+}
+
+// Pricer prices baskets of books
+type Pricer struct {
+	// the price of a single book in cents
+	BookPrice int
+	// Discounts[k] is the discount of a group of k distinct titles, the number of titles in a basket is limited by len(Discounts)-1
+	Discounts []int
+	// says whether the Discounts are in percent or in cents per book
+	Mode DiscountMode
+	// BundlePriceCents is the price in cents of a complete set of all len(Discounts)-1 distinct titles,
+	// it is used only in case it is cheaper than the discounted price of the set, zero means there is no bundle price
+	BundlePriceCents int
+	// Threshold is a discount of the whole basket that is used in case it is cheaper than the discounts of the groups
+	Threshold ThresholdDiscount
+	// Rounding converts the prices discounted by a percentage or by the Threshold to whole cents, the zero value is TruncateDown
+	Rounding Rounding
+}
+
+// ThresholdDiscount takes a fraction off the price of the whole basket once the basket contains enough distinct titles
+type ThresholdDiscount struct {
+	// the minimum number of distinct titles in a basket that qualifies for the discount
+	MinDistinct int
+	// the fraction of the price of the whole basket that is taken off, e.g. 0.25 for 25%, zero means there is no threshold discount
+	Fraction float64
+}
+
+// DefaultPricer prices books at $8 with 5%, 10%, 20% and 25% discount for a group of 2, 3, 4 and 5 distinct titles
+var DefaultPricer = Pricer{BookPrice: bookPrice, Discounts: discounts, Mode: Percentage}
+
+// groupPrice returns the price in cents of a group of size distinct titles
+func (p Pricer) groupPrice(size int) int {
+	price := p.discountedGroupPrice(size)
+	if p.BundlePriceCents > 0 && size == len(p.Discounts)-1 && p.BundlePriceCents < price {
+		return p.BundlePriceCents
+	}
+	return price
+}
+
+// discountedGroupPrice returns the price in cents of a group of size distinct titles without the bundle price
+func (p Pricer) discountedGroupPrice(size int) int {
+	if p.Mode == FlatPerBook {
+		return size * (p.BookPrice - p.Discounts[size])
+	}
+	return p.Rounding.cents(float64(size*p.BookPrice*(100-p.Discounts[size])) / 100)
+}
+
+// validateBasket checks that basket[i], the number of copies of the title i, is never negative
+// and that there are no more titles than the discounts table covers
+func (p Pricer) validateBasket(basket []int) error {
+	if len(basket) > len(p.Discounts)-1 {
+		return fmt.Errorf("basket contains %v titles, at most %v titles are supported", len(basket), len(p.Discounts)-1)
+	}
+	for title, count := range basket {
+		if count < 0 {
+			return fmt.Errorf("basket contains a negative number of copies (%v) of the title %v", count, title)
+		}
+	}
+	return nil
+}
+
+// Price returns the lowest price in cents of the basket, where basket[i] is the number of copies of the title i
+func (p Pricer) Price(basket []int) (int, error) {
+	total, _, err := p.PriceWithGroups(basket)
+	return total, err
+}
+
+// PriceWithGroups returns the lowest price in cents of the basket and the groups of distinct titles
+// that give the lowest price, each group being a list of title indices.
+// In case the Threshold discount is cheaper, its price is returned with the groups, although it doesn't depend on them.
+func (p Pricer) PriceWithGroups(basket []int) (int, [][]int, error) {
+	if err := p.validateBasket(basket); err != nil {
+		return 0, nil, err
+	}
+	groups := OptimalGroups(basket, func(size int) float64 {
+		return float64(p.groupPrice(size))
+	})
+	total := 0
+	for _, group := range groups {
+		total += p.groupPrice(len(group))
+	}
+	if thresholdTotal, ok := p.thresholdPrice(basket); ok && thresholdTotal < total {
+		total = thresholdTotal
+	}
+	return total, groups, nil
+}
+
+// PriceWithGiftWrap returns the lowest price in cents of the basket with each group gift-wrapped for wrapCentsPerGroup
+// and the groups that give the lowest price. The surcharge is a part of the cost of each group when the groups are searched for,
+// so a high surcharge may favor fewer, larger groups. The Threshold discount is not used, because it ignores the groups.
+// An error is returned in case the basket is invalid or wrapCentsPerGroup is negative.
+func (p Pricer) PriceWithGiftWrap(basket []int, wrapCentsPerGroup int) (int, [][]int, error) {
+	if err := p.validateBasket(basket); err != nil {
+		return 0, nil, err
+	}
+	if wrapCentsPerGroup < 0 {
+		return 0, nil, fmt.Errorf("gift wrap surcharge must not be negative, got %v", wrapCentsPerGroup)
+	}
+	groupPrice := func(size int) int {
+		return p.groupPrice(size) + wrapCentsPerGroup
+	}
+	groups := OptimalGroups(basket, func(size int) float64 {
+		return float64(groupPrice(size))
+	})
+	total := 0
+	for _, group := range groups {
+		total += groupPrice(len(group))
+	}
+	return total, groups, nil
+}
+
+// thresholdPrice returns the price in cents of the basket with the Threshold discount
+// or false in case the basket doesn't qualify for the Threshold discount
+func (p Pricer) thresholdPrice(basket []int) (int, bool) {
+	if p.Threshold.Fraction <= 0 {
+		return 0, false
+	}
+	books, distinct := 0, 0
+	for _, count := range basket {
+		books += count
+		if count > 0 {
+			distinct++
+		}
+	}
+	if distinct < p.Threshold.MinDistinct {
+		return 0, false
+	}
+	return p.Rounding.cents(float64(books*p.BookPrice) * (1 - p.Threshold.Fraction)), true
+}
+
+// Price returns the lowest price in cents of the basket using the DefaultPricer
+func Price(basket []int) (int, error) {
+	return DefaultPricer.Price(basket)
+}
+
+// PriceWithGroups returns the lowest price in cents of the basket and its groups using the DefaultPricer
+func PriceWithGroups(basket []int) (int, [][]int, error) {
+	return DefaultPricer.PriceWithGroups(basket)
+}
+
+// PriceWithGiftWrap returns the lowest price in cents of the basket with each group gift-wrapped and its groups using the DefaultPricer
+func PriceWithGiftWrap(basket []int, wrapCentsPerGroup int) (int, [][]int, error) {
+	return DefaultPricer.PriceWithGiftWrap(basket, wrapCentsPerGroup)
+}
+
+// PriceWithStock returns the lowest price in cents of the in-stock part of the basket.
+// Copies of a title that is not in stock can't be sold, so they are dropped from the basket
+// before grouping instead of failing the whole checkout.
+// An error is returned in case the basket is invalid or basket and inStock differ in length.
+func PriceWithStock(basket []int, inStock []bool) (int, error) {
+	if len(basket) != len(inStock) {
+		return 0, fmt.Errorf("basket has %v titles, but stock information is provided for %v titles", len(basket), len(inStock))
+	}
+	available := make([]int, len(basket))
+	for title, count := range basket {
+		if inStock[title] {
+			available[title] = count
+		}
+	}
+	return Price(available)
+}
+
+// PriceMixed returns the lowest price in cents of a basket of new and used copies,
+// where newCounts[i] and usedCounts[i] are the numbers of new and used copies of the title i.
+// Used copies don't qualify for the discounts, so the new copies are priced by Price
+// and each used copy is priced at usedPriceCents.
+// An error is returned in case the new copies are invalid, a number of used copies is negative
+// or newCounts and usedCounts differ in length.
+func PriceMixed(newCounts, usedCounts []int, usedPriceCents int) (int, error) {
+	if len(newCounts) != len(usedCounts) {
+		return 0, fmt.Errorf("basket has %v titles of new copies, but %v titles of used copies", len(newCounts), len(usedCounts))
+	}
+	total, err := Price(newCounts)
+	if err != nil {
+		return 0, err
+	}
+	for title, count := range usedCounts {
+		if count < 0 {
+			return 0, fmt.Errorf("basket contains a negative number of used copies (%v) of the title %v", count, title)
+		}
+		total += count * usedPriceCents
+	}
+	return total, nil
+}
+
+// MarginalCost returns how much the lowest price in cents of the basket increases in case one more copy of the title is added,
+// e.g. for an upsell. Thanks to the discounts it is often lower than the price of a single book.
+// An error is returned in case the basket or the title is invalid.
+func MarginalCost(basket []int, title int) (int, error) {
+	if title < 0 || title >= len(DefaultPricer.Discounts)-1 {
+		return 0, fmt.Errorf("title %v is out of range, at most %v titles are supported", title, len(DefaultPricer.Discounts)-1)
+	}
+	total, err := Price(basket)
+	if err != nil {
+		return 0, err
+	}
+	extended := make([]int, len(basket))
+	copy(extended, basket)
+	for len(extended) <= title {
+		extended = append(extended, 0)
+	}
+	extended[title]++
+	extendedTotal, err := Price(extended)
+	if err != nil {
+		return 0, err
+	}
+	return extendedTotal - total, nil
+}
+
+// PriceCapped returns the lowest price in cents of the basket with the number of copies of each title capped by the stock,
+// where stock[i] is the number of copies of the title i in stock, and the capped basket that is actually sold.
+// An error is returned in case the basket is invalid, a stock is negative or basket and stock differ in length.
+func PriceCapped(basket []int, stock []int) (int, []int, error) {
+	if len(basket) != len(stock) {
+		return 0, nil, fmt.Errorf("basket has %v titles, but stock is provided for %v titles", len(basket), len(stock))
+	}
+	capped := make([]int, len(basket))
+	for title, count := range basket {
+		if stock[title] < 0 {
+			return 0, nil, fmt.Errorf("stock of the title %v is negative (%v)", title, stock[title])
+		}
+		capped[title] = count
+		if count > stock[title] {
+			capped[title] = stock[title]
+		}
+	}
+	total, err := Price(capped)
+	if err != nil {
+		return 0, nil, err
+	}
+	return total, capped, nil
+}
+
+// OptimalGroups partitions the counts, where counts[i] is the number of items i, into groups of distinct items
+// so that the sum of groupCost over all groups is minimal, groupCost returns the cost of a group of size distinct items.
+// Each returned group is a sorted list of item indices.
+// In every step it is enough to try to form a group from the items with the highest count left,
+// so only the size of the next group is searched for.
+func OptimalGroups(counts []int, groupCost func(size int) float64) [][]int {
+	left := make([]int, len(counts))
+	copy(left, counts)
+	best := make(map[string]int)
+	cheapestRest(left, groupCost, make(map[string]float64), best)
+
+	groups := make([][]int, 0)
+	for {
+		size := best[countsKey(left)]
+		if size == 0 {
+			break
+		}
+		titles := titlesByCount(left)[:size]
+		sort.Ints(titles)
+		for _, title := range titles {
+			left[title]--
+		}
+		groups = append(groups, titles)
+	}
+	return groups
+}
+
+// cheapestRest returns the minimal cost of the counts and remembers the best size of the next group in best
+func cheapestRest(counts []int, groupCost func(size int) float64, memo map[string]float64, best map[string]int) float64 {
+	key := countsKey(counts)
+	if cost, ok := memo[key]; ok {
+		return cost
+	}
+	titles := titlesByCount(counts)
+	minCost, minSize := 0.0, 0
+	for size := 1; size <= len(titles); size++ {
+		for _, title := range titles[:size] {
+			counts[title]--
+		}
+		cost := groupCost(size) + cheapestRest(counts, groupCost, memo, best)
+		for _, title := range titles[:size] {
+			counts[title]++
+		}
+		if minSize == 0 || cost < minCost {
+			minCost, minSize = cost, size
+		}
+	}
+	memo[key] = minCost
+	best[key] = minSize
+	return minCost
+}
+
+// titlesByCount returns the titles that have at least one copy ordered by the number of copies, most copies first
+func titlesByCount(counts []int) []int {
+	titles := make([]int, 0, len(counts))
+	for title, count := range counts {
+		if count > 0 {
+			titles = append(titles, title)
+		}
+	}
+	sort.SliceStable(titles, func(i, j int) bool {
+		return counts[titles[i]] > counts[titles[j]]
+	})
+	return titles
+}
+
+// countsKey returns a key that is the same for all counts that differ only in the order of titles
+func countsKey(counts []int) string {
+	sorted := make([]int, len(counts))
+	copy(sorted, counts)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+	parts := make([]string, len(sorted))
+	for i, count := range sorted {
+		parts[i] = strconv.Itoa(count)
+	}
+	return strings.Join(parts, ",")
+}
+
+// NormalizeBasket returns a copy of the basket without the trailing titles with no copies,
+// e.g. []int{2, 1} for []int{2, 1, 0}, so that equivalent baskets can be compared and cached by their normalized form
+func NormalizeBasket(basket []int) []int {
+	n := len(basket)
+	for n > 0 && basket[n-1] == 0 {
+		n--
+	}
+	normalized := make([]int, n)
+	copy(normalized, basket)
+	return normalized
+}
+
+// BasketsEqual returns true in case the baskets contain the same number of copies of each title,
+// i.e. they differ only in trailing titles with no copies
+func BasketsEqual(a, b []int) bool {
+	a, b = NormalizeBasket(a), NormalizeBasket(b)
+	if len(a) != len(b) {
+		return false
+	}
+	for title := range a {
+		if a[title] != b[title] {
+			return false
+		}
+	}
+	return true
+}
+
+// SuggestAdditions suggests titles to add to the basket, one copy each, so that the average price per book is the lowest.
+// It returns the titles to add and the total price of the basket with the additions,
+// or (nil, current total price) in case no addition lowers the average price per book.
+// Among additions with the same average price per book the one with fewer books is suggested.
+// In case the basket is empty or invalid (nil, 0) is returned.
+func SuggestAdditions(basket []int) ([]int, int) {
+	total, err := Price(basket)
+	if err != nil || total == 0 {
+		return nil, 0
+	}
+	books := 0
+	for _, count := range basket {
+		books += count
+	}
+
+	titles := len(DefaultPricer.Discounts) - 1
+	var bestAdditions []int
+	bestTotal, bestBooks := total, books
+	for subset := 1; subset < 1<<uint(titles); subset++ {
+		extended := make([]int, titles)
+		copy(extended, basket)
+		additions := make([]int, 0, titles)
+		for title := 0; title < titles; title++ {
+			if subset&(1<<uint(title)) != 0 {
+				extended[title]++
+				additions = append(additions, title)
+			}
+		}
+		extendedTotal, _ := Price(extended)
+		extendedBooks := books + len(additions)
+		// compare extendedTotal/extendedBooks with bestTotal/bestBooks
+		if extendedTotal*bestBooks < bestTotal*extendedBooks ||
+			(extendedTotal*bestBooks == bestTotal*extendedBooks && bestAdditions != nil && len(additions) < len(bestAdditions)) {
+			bestAdditions, bestTotal, bestBooks = additions, extendedTotal, extendedBooks
+		}
+	}
+	return bestAdditions, bestTotal
+}
+
+// PlanPurchase returns the basket of copiesEach copies of each of the first titles titles, its lowest price in cents
+// and whether buying extra copies lowers the price per book, SuggestAdditions tells which copies to add.
+// An error is returned in case titles or copiesEach is negative or there are more titles than the discounts cover.
+func PlanPurchase(titles int, copiesEach int) (basket []int, total int, extraCopiesLower bool, err error) {
+	if titles < 0 || copiesEach < 0 {
+		return nil, 0, false, fmt.Errorf("number of titles and copies must not be negative, got %v titles of %v copies each", titles, copiesEach)
+	}
+	basket = make([]int, titles)
+	for title := range basket {
+		basket[title] = copiesEach
+	}
+	if total, err = Price(basket); err != nil {
+		return nil, 0, false, err
+	}
+	additions, _ := SuggestAdditions(basket)
+	return basket, total, additions != nil, nil
+}
+
+// CheapestIncluding returns the basket of totalBooks books of distinctTitles titles that contains the required title
+// and has the lowest price, e.g. for a "complete your collection" promo, and its price in cents.
+// It is the most even basket, the required title gets the most copies and the rest of titles follow in their order.
+// An error is returned in case distinctTitles is out of range, required is not one of the titles or totalBooks is less than 1.
+func CheapestIncluding(required int, totalBooks int, distinctTitles int) (basket []int, total int, err error) {
+	if distinctTitles < 1 || distinctTitles > len(DefaultPricer.Discounts)-1 {
+		return nil, 0, fmt.Errorf("number of distinct titles must be between 1 and %v, got %v", len(DefaultPricer.Discounts)-1, distinctTitles)
+	}
+	if required < 0 || required >= distinctTitles {
+		return nil, 0, fmt.Errorf("required title %v is not one of the %v titles", required, distinctTitles)
+	}
+	if totalBooks < 1 {
+		return nil, 0, fmt.Errorf("basket must contain at least one book, got %v", totalBooks)
+	}
+	// Adding each group of any grouping to the titles with the fewest copies so far keeps the numbers of copies
+	// within one of each other, so the most even basket can be grouped in every way any other basket can.
+	// Therefore it has the lowest price, which is the cheapest way to split totalBooks into groups,
+	// and it is also the cheapest basket for the Threshold discount, because it has the most distinct titles.
+	counts := make([]int, distinctTitles)
+	for i := range counts {
+		counts[i] = totalBooks / distinctTitles
+		if i < totalBooks%distinctTitles {
+			counts[i]++
+		}
+	}
+	basket = make([]int, distinctTitles)
+	basket[required] = counts[0]
+	rest := counts[1:]
+	for title := range basket {
+		if title != required {
+			basket[title], rest = rest[0], rest[1:]
+		}
+	}
+	total = DefaultPricer.cheapestSplit(totalBooks, distinctTitles)
+	if thresholdTotal, ok := DefaultPricer.thresholdPrice(basket); ok && thresholdTotal < total {
+		total = thresholdTotal
+	}
+	return basket, total, nil
+}
+
+// cheapestSplit returns the lowest price in cents of books split into groups of at most maxSize distinct titles
+func (p Pricer) cheapestSplit(books, maxSize int) int {
+	// cheapest[b%len(cheapest)] is the lowest price of b books, only the last maxSize prices are needed
+	cheapest := make([]int, maxSize+1)
+	for b := 1; b <= books; b++ {
+		price := -1
+		for size := 1; size <= maxSize && size <= b; size++ {
+			if candidate := p.groupPrice(size) + cheapest[(b-size)%len(cheapest)]; price == -1 || candidate < price {
+				price = candidate
+			}
+		}
+		cheapest[b%len(cheapest)] = price
+	}
+	return cheapest[books%len(cheapest)]
+}
+
+// MinGroups returns the fewest groups of distinct titles (e.g. bags) that hold all books of the basket and the groups.
+// Unlike Price it doesn't minimize the price, the fewest groups are as many as copies of the most bought title,
+// the i-th group contains all titles with more than i copies.
+func MinGroups(basket []int) (int, [][]int) {
+	groupCount := 0
+	for _, count := range basket {
+		if count > groupCount {
+			groupCount = count
+		}
+	}
+	groups := make([][]int, groupCount)
+	for i := range groups {
+		groups[i] = make([]int, 0, len(basket))
+		for title, count := range basket {
+			if count > i {
+				groups[i] = append(groups[i], title)
+			}
+		}
+	}
+	return groupCount, groups
+}
+
+// TitleRevenue returns for each title of the basket its share of the lowest price of the basket in cents.
+// The price of each group is split evenly among the titles of the group,
+// the cents that can't be split evenly go to the titles with the lowest index, one cent each,
+// so that the shares always sum up to the price of the basket.
+func TitleRevenue(basket []int) ([]int, error) {
+	_, groups, err := PriceWithGroups(basket)
+	if err != nil {
+		return nil, err
+	}
+	revenue := make([]int, len(basket))
+	for _, group := range groups {
+		price := DefaultPricer.groupPrice(len(group))
+		share, remainder := price/len(group), price%len(group)
+		for i, title := range group {
+			revenue[title] += share
+			if i < remainder {
+				revenue[title]++
+			}
+		}
+	}
+	return revenue, nil
+}
+
+// FormatCents formats the price in cents as dollars, e.g. "$25.60" for 2560
+func FormatCents(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s$%d.%02d", sign, cents/100, cents%100)
+}
+
+// ExplainGrouping returns a human readable explanation of the lowest price of the basket using the DefaultPricer, e.g.
+// "Group 1: 4 titles at 20% off = $25.60; Group 2: 1 title = $8.00; Total $33.60; you saved $6.40."
+func ExplainGrouping(basket []int) (string, error) {
+	total, groups, err := PriceWithGroups(basket)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, 0, len(groups)+2)
+	books := 0
+	for i, group := range groups {
+		size := len(group)
+		books += size
+		titles := "titles"
+		if size == 1 {
+			titles = "title"
+		}
+		discount := ""
+		if DefaultPricer.Discounts[size] > 0 {
+			discount = fmt.Sprintf(" at %v%% off", DefaultPricer.Discounts[size])
+		}
+		parts = append(parts, fmt.Sprintf("Group %v: %v %s%s = %s", i+1, size, titles, discount, FormatCents(DefaultPricer.groupPrice(size))))
+	}
+	parts = append(parts, "Total "+FormatCents(total), "you saved "+FormatCents(books*DefaultPricer.BookPrice-total))
+	return strings.Join(parts, "; ") + ".", nil
+}
+
+// PricePerBook returns the average price of a book of the basket in dollars after the optimal grouping,
+// e.g. for shelf labels. An error is returned in case the basket is empty or invalid.
+func PricePerBook(basket []int) (float64, error) {
+	total, err := Price(basket)
+	if err != nil {
+		return 0, err
+	}
+	books := 0
+	for _, count := range basket {
+		books += count
+	}
+	if books == 0 {
+		return 0, fmt.Errorf("basket contains no book")
+	}
+	return float64(total) / float64(books) / 100, nil
+}
+
+// Savings returns the price in cents of the books of the basket without any discount and how many cents the discounts save
+func Savings(basket []int) (fullTotal int, saved int, err error) {
+	total, err := Price(basket)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, count := range basket {
+		fullTotal += count * DefaultPricer.BookPrice
+	}
+	return fullTotal, fullTotal - total, nil
+}
+
+// EffectiveDiscount returns the fraction of the price of the basket the discounts save, e.g. 0.2 in case 1280 of 6400 cents are saved.
+// Zero is returned for an empty basket.
+func EffectiveDiscount(basket []int) (float64, error) {
+	fullTotal, saved, err := Savings(basket)
+	if err != nil || fullTotal == 0 {
+		return 0, err
+	}
+	return float64(saved) / float64(fullTotal), nil
+}
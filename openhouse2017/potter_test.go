@@ -1,6 +1,10 @@
 package potter
 
-import "testing"
+import (
+	"math"
+	"reflect"
+	"testing"
+)
 
 func cost(amount int) int {
 	return amount * 8
@@ -22,3 +26,541 @@ func Test_zero(t *testing.T) {
 	}
 	// END OMIT
 }
+
+func TestPriceWithStock(t *testing.T) {
+	tests := []struct {
+		basket  []int
+		inStock []bool
+		want    int
+	}{
+		{[]int{}, []bool{}, 0},
+		{[]int{1}, []bool{false}, 0},
+		{[]int{2, 2, 2, 1, 1}, []bool{true, true, true, true, true}, 5120},
+		// without the last title two groups of 4 are no longer possible
+		{[]int{2, 2, 2, 1, 1}, []bool{true, true, true, true, false}, 4720},
+	}
+	for _, tt := range tests {
+		if got, err := PriceWithStock(tt.basket, tt.inStock); err != nil || got != tt.want {
+			t.Errorf("PriceWithStock(%v, %v) = (%v, %v), want (%v, %v)", tt.basket, tt.inStock, got, err, tt.want, nil)
+		}
+	}
+
+	if got, err := PriceWithStock([]int{1, 1}, []bool{true}); err == nil {
+		t.Errorf("PriceWithStock(%v, %v) = (%v, %v), want an error", []int{1, 1}, []bool{true}, got, err)
+	}
+}
+
+func TestPriceMixed(t *testing.T) {
+	tests := []struct {
+		newCounts  []int
+		usedCounts []int
+		want       int
+	}{
+		{[]int{}, []int{}, 0},
+		{[]int{0, 0}, []int{1, 2}, 1500},
+		// the used copies of the titles 0 and 1 don't complete the series, so the new copies form a group of 3 titles
+		{[]int{0, 0, 1, 1, 1}, []int{1, 1, 0, 0, 1}, 2160 + 1500},
+		{[]int{2, 2, 2, 1, 1}, []int{1, 0, 0, 0, 0}, 5120 + 500},
+	}
+	for _, tt := range tests {
+		if got, err := PriceMixed(tt.newCounts, tt.usedCounts, 500); err != nil || got != tt.want {
+			t.Errorf("PriceMixed(%v, %v, 500) = (%v, %v), want (%v, %v)", tt.newCounts, tt.usedCounts, got, err, tt.want, nil)
+		}
+	}
+
+	for _, tt := range []struct{ newCounts, usedCounts []int }{
+		{[]int{1, 1}, []int{1}},
+		{[]int{1}, []int{-1}},
+		{[]int{-1}, []int{1}},
+	} {
+		if got, err := PriceMixed(tt.newCounts, tt.usedCounts, 500); err == nil {
+			t.Errorf("PriceMixed(%v, %v, 500) = (%v, %v), want an error", tt.newCounts, tt.usedCounts, got, err)
+		}
+	}
+}
+
+func TestSuggestAdditions(t *testing.T) {
+	tests := []struct {
+		basket        []int
+		wantAdditions []int
+		wantTotal     int
+	}{
+		{[]int{}, nil, 0},
+		// a group of 4 titles costs 6.40 per book, a group of 5 titles 6.00 per book
+		{[]int{1, 1, 1, 1}, []int{4}, 3000},
+		{[]int{0, 1, 1, 1, 1}, []int{0}, 3000},
+		// a group of 2 titles costs 7.60 per book, completing the series costs 6.00 per book
+		{[]int{1, 1}, []int{2, 3, 4}, 3000},
+		{[]int{1, 1, 1, 1, 1}, nil, 3000},
+	}
+	for _, tt := range tests {
+		gotAdditions, gotTotal := SuggestAdditions(tt.basket)
+		if !reflect.DeepEqual(gotAdditions, tt.wantAdditions) || gotTotal != tt.wantTotal {
+			t.Errorf("SuggestAdditions(%v) = (%v, %v), want (%v, %v)", tt.basket, gotAdditions, gotTotal, tt.wantAdditions, tt.wantTotal)
+		}
+	}
+}
+
+func TestMinGroups(t *testing.T) {
+	tests := []struct {
+		basket     []int
+		wantCount  int
+		wantGroups [][]int
+	}{
+		{[]int{}, 0, [][]int{}},
+		{[]int{1, 1, 1}, 1, [][]int{{0, 1, 2}}},
+		{[]int{3, 1, 1}, 3, [][]int{{0, 1, 2}, {0}, {0}}},
+		{[]int{2, 2, 2, 1, 1}, 2, [][]int{{0, 1, 2, 3, 4}, {0, 1, 2}}},
+	}
+	for _, tt := range tests {
+		gotCount, gotGroups := MinGroups(tt.basket)
+		if gotCount != tt.wantCount || !reflect.DeepEqual(gotGroups, tt.wantGroups) {
+			t.Errorf("MinGroups(%v) = (%v, %v), want (%v, %v)", tt.basket, gotCount, gotGroups, tt.wantCount, tt.wantGroups)
+		}
+	}
+}
+
+func TestTitleRevenue(t *testing.T) {
+	tests := []struct {
+		basket []int
+		want   []int
+	}{
+		{[]int{}, []int{}},
+		{[]int{1}, []int{800}},
+		{[]int{1, 1}, []int{760, 760}},
+		{[]int{2, 2, 2, 1, 1}, []int{1280, 1280, 1280, 640, 640}},
+	}
+	for _, tt := range tests {
+		got, err := TitleRevenue(tt.basket)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("TitleRevenue(%v) = (%v, %v), want (%v, %v)", tt.basket, got, err, tt.want, nil)
+		}
+		total, _ := Price(tt.basket)
+		sum := 0
+		for _, share := range got {
+			sum += share
+		}
+		if sum != total {
+			t.Errorf("TitleRevenue(%v) = %v sums up to %v, want %v", tt.basket, got, sum, total)
+		}
+	}
+}
+
+func TestPricerFlatPerBook(t *testing.T) {
+	percentage := DefaultPricer
+	flat := Pricer{BookPrice: 800, Discounts: []int{0, 0, 40, 80, 160, 240}, Mode: FlatPerBook}
+	basket := []int{2, 2, 2, 1, 1}
+
+	// two groups of 4 titles are cheaper with the percentage discounts
+	total, groups, err := percentage.PriceWithGroups(basket)
+	if want := [][]int{{0, 1, 2, 3}, {0, 1, 2, 4}}; err != nil || total != 5120 || !reflect.DeepEqual(groups, want) {
+		t.Errorf("PriceWithGroups(%v) with percentage discounts = (%v, %v, %v), want (%v, %v, %v)", basket, total, groups, err, 5120, want, nil)
+	}
+	// a group of 5 titles and a group of 3 titles are cheaper with the flat discounts
+	total, groups, err = flat.PriceWithGroups(basket)
+	if want := [][]int{{0, 1, 2}, {0, 1, 2, 3, 4}}; err != nil || total != 4960 || !reflect.DeepEqual(groups, want) {
+		t.Errorf("PriceWithGroups(%v) with flat discounts = (%v, %v, %v), want (%v, %v, %v)", basket, total, groups, err, 4960, want, nil)
+	}
+
+	if total, err := flat.Price([]int{1, 1}); err != nil || total != 1520 {
+		t.Errorf("Price(%v) with flat discounts = (%v, %v), want (%v, %v)", []int{1, 1}, total, err, 1520, nil)
+	}
+}
+
+func TestOptimalGroups(t *testing.T) {
+	bookCost := func(size int) float64 {
+		return float64(DefaultPricer.groupPrice(size))
+	}
+	// penalizes large groups, so single items are the cheapest
+	squareCost := func(size int) float64 {
+		return float64(size * size)
+	}
+	tests := []struct {
+		counts    []int
+		groupCost func(size int) float64
+		want      [][]int
+	}{
+		{[]int{}, bookCost, [][]int{}},
+		{[]int{1, 1}, bookCost, [][]int{{0, 1}}},
+		{[]int{2, 2, 2, 1, 1}, bookCost, [][]int{{0, 1, 2, 3}, {0, 1, 2, 4}}},
+		{[]int{1, 1}, squareCost, [][]int{{0}, {1}}},
+		{[]int{2, 0, 1}, squareCost, [][]int{{0}, {0}, {2}}},
+	}
+	for _, tt := range tests {
+		if got := OptimalGroups(tt.counts, tt.groupCost); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("OptimalGroups(%v) = %v, want %v", tt.counts, got, tt.want)
+		}
+	}
+}
+
+func TestPricerBundlePrice(t *testing.T) {
+	bundle := DefaultPricer
+	bundle.BundlePriceCents = 2500
+	basket := []int{2, 2, 2, 1, 1}
+
+	// the bundle undercuts the 25% discount (3000), so a complete set and a group of 3 titles beat two groups of 4 titles
+	total, groups, err := bundle.PriceWithGroups(basket)
+	if want := [][]int{{0, 1, 2}, {0, 1, 2, 3, 4}}; err != nil || total != 4660 || !reflect.DeepEqual(groups, want) {
+		t.Errorf("PriceWithGroups(%v) with bundle price = (%v, %v, %v), want (%v, %v, %v)", basket, total, groups, err, 4660, want, nil)
+	}
+	if total, err := bundle.Price([]int{1, 1, 1, 1}); err != nil || total != 2560 {
+		t.Errorf("Price(%v) with bundle price = (%v, %v), want (%v, %v)", []int{1, 1, 1, 1}, total, err, 2560, nil)
+	}
+
+	// a bundle price above the discounted price of a complete set is not used
+	bundle.BundlePriceCents = 3500
+	if total, err := bundle.Price([]int{1, 1, 1, 1, 1}); err != nil || total != 3000 {
+		t.Errorf("Price(%v) with bundle price = (%v, %v), want (%v, %v)", []int{1, 1, 1, 1, 1}, total, err, 3000, nil)
+	}
+}
+
+func TestExplainGrouping(t *testing.T) {
+	tests := []struct {
+		basket []int
+		want   string
+	}{
+		{[]int{}, "Total $0.00; you saved $0.00."},
+		{[]int{1, 1, 1, 1, 1}, "Group 1: 5 titles at 25% off = $30.00; Total $30.00; you saved $10.00."},
+		{[]int{2, 1, 1, 1}, "Group 1: 1 title = $8.00; Group 2: 4 titles at 20% off = $25.60; Total $33.60; you saved $6.40."},
+	}
+	for _, tt := range tests {
+		if got, err := ExplainGrouping(tt.basket); err != nil || got != tt.want {
+			t.Errorf("ExplainGrouping(%v) = (%q, %v), want (%q, %v)", tt.basket, got, err, tt.want, nil)
+		}
+	}
+
+	if got, err := ExplainGrouping([]int{-1}); err == nil {
+		t.Errorf("ExplainGrouping(%v) = (%q, %v), want an error", []int{-1}, got, err)
+	}
+}
+
+func TestFormatCents(t *testing.T) {
+	tests := []struct {
+		in   int
+		want string
+	}{
+		{0, "$0.00"},
+		{5, "$0.05"},
+		{2560, "$25.60"},
+		{-640, "-$6.40"},
+	}
+	for _, tt := range tests {
+		if got := FormatCents(tt.in); got != tt.want {
+			t.Errorf("FormatCents(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPricePerBook(t *testing.T) {
+	tests := []struct {
+		basket []int
+		want   float64
+	}{
+		{[]int{1}, 8.00},
+		{[]int{2, 2, 2, 1, 1}, 6.40},
+	}
+	for _, tt := range tests {
+		if got, err := PricePerBook(tt.basket); err != nil || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("PricePerBook(%v) = (%v, %v), want (%v, %v)", tt.basket, got, err, tt.want, nil)
+		}
+	}
+
+	for _, basket := range [][]int{{}, {0, 0}, {-1}} {
+		if got, err := PricePerBook(basket); err == nil {
+			t.Errorf("PricePerBook(%v) = (%v, %v), want an error", basket, got, err)
+		}
+	}
+}
+
+func TestPricerThresholdDiscount(t *testing.T) {
+	threshold := DefaultPricer
+	threshold.Threshold = ThresholdDiscount{MinDistinct: 5, Fraction: 0.25}
+	tests := []struct {
+		basket []int
+		want   int
+	}{
+		// 25% off all 8 books beats two groups of 4 titles (5120)
+		{[]int{2, 2, 2, 1, 1}, 4800},
+		{[]int{1, 1, 1, 1, 1}, 3000},
+		// 4 distinct titles don't qualify
+		{[]int{2, 2, 2, 2, 0}, 5120},
+	}
+	for _, tt := range tests {
+		if got, err := threshold.Price(tt.basket); err != nil || got != tt.want {
+			t.Errorf("Price(%v) with threshold discount = (%v, %v), want (%v, %v)", tt.basket, got, err, tt.want, nil)
+		}
+	}
+
+	// the discount of the groups is cheaper than a small threshold discount
+	threshold.Threshold = ThresholdDiscount{MinDistinct: 2, Fraction: 0.01}
+	if got, err := threshold.Price([]int{1, 1, 1, 1, 1}); err != nil || got != 3000 {
+		t.Errorf("Price(%v) with threshold discount = (%v, %v), want (%v, %v)", []int{1, 1, 1, 1, 1}, got, err, 3000, nil)
+	}
+}
+
+func TestPricerRounding(t *testing.T) {
+	tests := []struct {
+		bookPrice int
+		rounding  Rounding
+		want      int
+	}{
+		// half of 813 is 406.5
+		{813, TruncateDown, 406},
+		{813, RoundHalfUp, 407},
+		{813, RoundHalfToEven, 406},
+		// half of 815 is 407.5
+		{815, TruncateDown, 407},
+		{815, RoundHalfUp, 408},
+		{815, RoundHalfToEven, 408},
+	}
+	for _, tt := range tests {
+		p := Pricer{BookPrice: tt.bookPrice, Discounts: []int{0, 50}, Mode: Percentage, Rounding: tt.rounding}
+		if got, err := p.Price([]int{1}); err != nil || got != tt.want {
+			t.Errorf("Price(%v) of a book for %v with 50%% discount and rounding %v = (%v, %v), want (%v, %v)", []int{1}, tt.bookPrice, tt.rounding, got, err, tt.want, nil)
+		}
+	}
+
+	// the default rounding of a threshold discount is TruncateDown
+	threshold := Pricer{BookPrice: 813, Discounts: []int{0, 0}, Mode: Percentage, Threshold: ThresholdDiscount{MinDistinct: 1, Fraction: 0.5}}
+	if got, err := threshold.Price([]int{1}); err != nil || got != 406 {
+		t.Errorf("Price(%v) of a book for %v with 50%% threshold discount = (%v, %v), want (%v, %v)", []int{1}, 813, got, err, 406, nil)
+	}
+}
+
+func TestMarginalCost(t *testing.T) {
+	tests := []struct {
+		basket []int
+		title  int
+		want   int
+	}{
+		{[]int{}, 0, 800},
+		// the copy completes the series, the group of 4 titles (2560) becomes a group of 5 titles (3000)
+		{[]int{1, 1, 1, 1}, 4, 440},
+		{[]int{1, 1, 1, 1, 1}, 0, 800},
+		{[]int{1}, 1, 720},
+	}
+	for _, tt := range tests {
+		if got, err := MarginalCost(tt.basket, tt.title); err != nil || got != tt.want {
+			t.Errorf("MarginalCost(%v, %v) = (%v, %v), want (%v, %v)", tt.basket, tt.title, got, err, tt.want, nil)
+		}
+	}
+
+	for _, title := range []int{-1, 5} {
+		if got, err := MarginalCost([]int{1}, title); err == nil {
+			t.Errorf("MarginalCost(%v, %v) = (%v, %v), want an error", []int{1}, title, got, err)
+		}
+	}
+	if got, err := MarginalCost([]int{-1}, 0); err == nil {
+		t.Errorf("MarginalCost(%v, %v) = (%v, %v), want an error", []int{-1}, 0, got, err)
+	}
+}
+
+func TestPlanPurchase(t *testing.T) {
+	tests := []struct {
+		titles, copiesEach   int
+		wantBasket           []int
+		wantTotal            int
+		wantExtraCopiesLower bool
+	}{
+		{5, 1, []int{1, 1, 1, 1, 1}, 3000, false},
+		{5, 2, []int{2, 2, 2, 2, 2}, 6000, false},
+		// buying the fifth title lowers the price per book of 4 titles
+		{4, 1, []int{1, 1, 1, 1}, 2560, true},
+		{0, 1, []int{}, 0, false},
+	}
+	for _, tt := range tests {
+		basket, total, extraCopiesLower, err := PlanPurchase(tt.titles, tt.copiesEach)
+		if err != nil || !reflect.DeepEqual(basket, tt.wantBasket) || total != tt.wantTotal || extraCopiesLower != tt.wantExtraCopiesLower {
+			t.Errorf("PlanPurchase(%v, %v) = (%v, %v, %v, %v), want (%v, %v, %v, %v)", tt.titles, tt.copiesEach, basket, total, extraCopiesLower, err, tt.wantBasket, tt.wantTotal, tt.wantExtraCopiesLower, nil)
+		}
+	}
+
+	for _, in := range [][2]int{{6, 1}, {-1, 1}, {5, -1}} {
+		if basket, total, extraCopiesLower, err := PlanPurchase(in[0], in[1]); err == nil {
+			t.Errorf("PlanPurchase(%v, %v) = (%v, %v, %v, %v), want an error", in[0], in[1], basket, total, extraCopiesLower, err)
+		}
+	}
+
+	basket, _, _, _ := PlanPurchase(4, 1)
+	if additions, total := SuggestAdditions(basket); !reflect.DeepEqual(additions, []int{4}) || total != 3000 {
+		t.Errorf("SuggestAdditions(%v) = (%v, %v), want (%v, %v)", basket, additions, total, []int{4}, 3000)
+	}
+}
+
+func TestEffectiveDiscount(t *testing.T) {
+	tests := []struct {
+		basket []int
+		want   float64
+	}{
+		{[]int{}, 0},
+		{[]int{1}, 0},
+		{[]int{2, 2, 2, 1, 1}, 0.2},
+		{[]int{1, 1, 1, 1, 1}, 0.25},
+	}
+	for _, tt := range tests {
+		if got, err := EffectiveDiscount(tt.basket); err != nil || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("EffectiveDiscount(%v) = (%v, %v), want (%v, %v)", tt.basket, got, err, tt.want, nil)
+		}
+	}
+	if got, err := EffectiveDiscount([]int{-1}); err == nil {
+		t.Errorf("EffectiveDiscount(%v) = (%v, %v), want an error", []int{-1}, got, err)
+	}
+
+	if fullTotal, saved, err := Savings([]int{2, 2, 2, 1, 1}); err != nil || fullTotal != 6400 || saved != 1280 {
+		t.Errorf("Savings(%v) = (%v, %v, %v), want (%v, %v, %v)", []int{2, 2, 2, 1, 1}, fullTotal, saved, err, 6400, 1280, nil)
+	}
+}
+
+func TestPriceCapped(t *testing.T) {
+	tests := []struct {
+		basket     []int
+		stock      []int
+		wantTotal  int
+		wantCapped []int
+	}{
+		{[]int{}, []int{}, 0, []int{}},
+		{[]int{2, 2, 2, 1, 1}, []int{9, 9, 9, 9, 9}, 5120, []int{2, 2, 2, 1, 1}},
+		// without the last title two groups of 4 titles are no longer possible
+		{[]int{2, 2, 2, 1, 1}, []int{2, 2, 2, 1, 0}, 4720, []int{2, 2, 2, 1, 0}},
+		{[]int{3, 1}, []int{1, 5}, 1520, []int{1, 1}},
+	}
+	for _, tt := range tests {
+		total, capped, err := PriceCapped(tt.basket, tt.stock)
+		if err != nil || total != tt.wantTotal || !reflect.DeepEqual(capped, tt.wantCapped) {
+			t.Errorf("PriceCapped(%v, %v) = (%v, %v, %v), want (%v, %v, %v)", tt.basket, tt.stock, total, capped, err, tt.wantTotal, tt.wantCapped, nil)
+		}
+	}
+
+	for _, tt := range []struct{ basket, stock []int }{
+		{[]int{1, 1}, []int{1}},
+		{[]int{1}, []int{-1}},
+		{[]int{-1}, []int{1}},
+	} {
+		if total, capped, err := PriceCapped(tt.basket, tt.stock); err == nil {
+			t.Errorf("PriceCapped(%v, %v) = (%v, %v, %v), want an error", tt.basket, tt.stock, total, capped, err)
+		}
+	}
+}
+
+func TestNormalizeBasket(t *testing.T) {
+	tests := []struct {
+		basket []int
+		want   []int
+	}{
+		{[]int{2, 1, 0}, []int{2, 1}},
+		{[]int{2, 0, 1}, []int{2, 0, 1}},
+		{[]int{0, 0}, []int{}},
+		{nil, []int{}},
+	}
+	for _, tt := range tests {
+		if got := NormalizeBasket(tt.basket); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NormalizeBasket(%v) = %v, want %v", tt.basket, got, tt.want)
+		}
+	}
+
+	basket := []int{1, 0}
+	NormalizeBasket(basket)[0] = 5
+	if basket[0] != 1 {
+		t.Errorf("NormalizeBasket(%v) modified the basket", []int{1, 0})
+	}
+}
+
+func TestBasketsEqual(t *testing.T) {
+	tests := []struct {
+		a, b []int
+		want bool
+	}{
+		{[]int{2, 1}, []int{2, 1, 0}, true},
+		{[]int{2, 1, 0, 0}, []int{2, 1, 0}, true},
+		{[]int{}, []int{0, 0}, true},
+		{[]int{2, 0, 1}, []int{1, 0, 2}, false},
+		{[]int{2, 1}, []int{2, 1, 1}, false},
+	}
+	for _, tt := range tests {
+		if got := BasketsEqual(tt.a, tt.b); got != tt.want {
+			t.Errorf("BasketsEqual(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestPriceWithGiftWrap(t *testing.T) {
+	// 2 groups of 4 titles (5120) plus 2 wraps
+	if total, groups, err := PriceWithGiftWrap([]int{2, 2, 2, 1, 1}, 100); err != nil || total != 5320 || len(groups) != 2 {
+		t.Errorf("PriceWithGiftWrap(%v, %v) = (%v, %v, %v), want (%v, 2 groups, %v)", []int{2, 2, 2, 1, 1}, 100, total, groups, err, 5320, nil)
+	}
+
+	// a group of 2 titles is more expensive than 2 single books (1600 vs 1400) ...
+	p := Pricer{BookPrice: 800, Discounts: []int{0, 100, 0}, Mode: FlatPerBook}
+	tests := []struct {
+		wrap       int
+		wantTotal  int
+		wantGroups [][]int
+	}{
+		{0, 1400, [][]int{{0}, {1}}},
+		// ... but a high wrap cost favors a single group (1600 + 300 vs 2 * (700 + 300))
+		{300, 1900, [][]int{{0, 1}}},
+	}
+	for _, tt := range tests {
+		total, groups, err := p.PriceWithGiftWrap([]int{1, 1}, tt.wrap)
+		if err != nil || total != tt.wantTotal || !reflect.DeepEqual(groups, tt.wantGroups) {
+			t.Errorf("PriceWithGiftWrap(%v, %v) = (%v, %v, %v), want (%v, %v, %v)", []int{1, 1}, tt.wrap, total, groups, err, tt.wantTotal, tt.wantGroups, nil)
+		}
+	}
+
+	if total, groups, err := PriceWithGiftWrap([]int{1}, -1); err == nil {
+		t.Errorf("PriceWithGiftWrap(%v, %v) = (%v, %v, %v), want an error", []int{1}, -1, total, groups, err)
+	}
+}
+
+func TestCheapestIncluding(t *testing.T) {
+	tests := []struct {
+		required, totalBooks, distinctTitles int
+		wantBasket                           []int
+		wantTotal                            int
+	}{
+		{0, 5, 5, []int{1, 1, 1, 1, 1}, 3000},
+		// 2 groups of 4 titles are cheaper than a group of 5 titles and a group of 3 titles
+		{4, 8, 5, []int{2, 2, 1, 1, 2}, 5120},
+		{1, 3, 2, []int{1, 2}, 2320},
+		{0, 1, 1, []int{1}, 800},
+	}
+	for _, tt := range tests {
+		basket, total, err := CheapestIncluding(tt.required, tt.totalBooks, tt.distinctTitles)
+		if err != nil || total != tt.wantTotal || !reflect.DeepEqual(basket, tt.wantBasket) {
+			t.Errorf("CheapestIncluding(%v, %v, %v) = (%v, %v, %v), want (%v, %v, %v)", tt.required, tt.totalBooks, tt.distinctTitles, basket, total, err, tt.wantBasket, tt.wantTotal, nil)
+		}
+	}
+
+	for _, tt := range []struct{ required, totalBooks, distinctTitles int }{
+		{0, 5, 0},
+		{0, 5, 6},
+		{5, 5, 5},
+		{-1, 5, 5},
+		{0, 0, 5},
+	} {
+		if basket, total, err := CheapestIncluding(tt.required, tt.totalBooks, tt.distinctTitles); err == nil {
+			t.Errorf("CheapestIncluding(%v, %v, %v) = (%v, %v, %v), want an error", tt.required, tt.totalBooks, tt.distinctTitles, basket, total, err)
+		}
+	}
+}
+
+func TestCheapestIncludingMatchesPrice(t *testing.T) {
+	for distinctTitles := 1; distinctTitles <= 5; distinctTitles++ {
+		for totalBooks := 1; totalBooks <= 20; totalBooks++ {
+			basket, total, err := CheapestIncluding(0, totalBooks, distinctTitles)
+			if err != nil {
+				t.Fatalf("CheapestIncluding(%v, %v, %v) returned error %v", 0, totalBooks, distinctTitles, err)
+			}
+			if price, err := Price(basket); err != nil || price != total {
+				t.Errorf("CheapestIncluding(%v, %v, %v) = (%v, %v, nil), but Price(%v) = (%v, %v)", 0, totalBooks, distinctTitles, basket, total, basket, price, err)
+			}
+		}
+	}
+}
+
+func TestCheapestIncludingLarge(t *testing.T) {
+	basket, total, err := CheapestIncluding(2, 100000, 5)
+	if want := []int{20000, 20000, 20000, 20000, 20000}; err != nil || total != 20000*3000 || !reflect.DeepEqual(basket, want) {
+		t.Errorf("CheapestIncluding(%v, %v, %v) = (%v, %v, %v), want (%v, %v, %v)", 2, 100000, 5, basket, total, err, want, 20000*3000, nil)
+	}
+}
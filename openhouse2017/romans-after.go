@@ -17,6 +17,10 @@ func ToInt(i string) (int, error) { // HL
 		return -1, Invalid
 	}
 	//END OMIT
+	i, err := trimNumeral(i)
+	if err != nil {
+		return -1, err
+	}
 	m := map[string]int{
 		"I": 1,
 		"V": 5,
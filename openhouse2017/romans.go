@@ -0,0 +1,626 @@
+package romans
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var OutOfRange = errors.New("only numbers from 1 to 3999 can be written as a roman numeral")
+
+// symbols lists the values of the symbols and subtractive pairs of symbols used in canonical roman numerals from the largest
+var symbols = []struct {
+	value   int
+	numeral string
+}{
+	{1000, "M"},
+	{900, "CM"},
+	{500, "D"},
+	{400, "CD"},
+	{100, "C"},
+	{90, "XC"},
+	{50, "L"},
+	{40, "XL"},
+	{10, "X"},
+	{9, "IX"},
+	{5, "V"},
+	{4, "IV"},
+	{1, "I"},
+}
+
+// IntToRoman returns the canonical roman numeral of n
+func IntToRoman(n int) (string, error) {
+	if n < 1 || n > 3999 {
+		return "", OutOfRange
+	}
+	roman := ""
+	for _, s := range symbols {
+		for n >= s.value {
+			roman += s.numeral
+			n -= s.value
+		}
+	}
+	return roman, nil
+}
+
+// IntToRomanPadded returns the canonical roman numeral of n right-aligned with spaces in a field of width runes,
+// e.g. "   IV" for 4 and 5, so that a column of numerals is aligned.
+// OutOfRange is returned for n out of range, an error is returned in case the numeral is longer than width.
+func IntToRomanPadded(n, width int) (string, error) {
+	roman, err := IntToRoman(n)
+	if err != nil {
+		return "", err
+	}
+	if len(roman) > width {
+		return "", fmt.Errorf("roman numeral %q of %v does not fit into width %v", roman, n, width)
+	}
+	return fmt.Sprintf("%*s", width, roman), nil
+}
+
+// SymbolFrequency returns how many times each symbol appears in the canonical roman numeral of n,
+// e.g. X:3, V:1 and I:3 for 38 ("XXXVIII"). OutOfRange is returned for n out of range.
+func SymbolFrequency(n int) (map[rune]int, error) {
+	roman, err := IntToRoman(n)
+	if err != nil {
+		return nil, err
+	}
+	frequency := make(map[rune]int)
+	for _, symbol := range roman {
+		frequency[symbol]++
+	}
+	return frequency, nil
+}
+
+// additiveSymbols lists the values of the symbols used in additive roman numerals from the largest
+var additiveSymbols = []struct {
+	value   int
+	numeral string
+}{
+	{1000, "M"},
+	{500, "D"},
+	{100, "C"},
+	{50, "L"},
+	{10, "X"},
+	{5, "V"},
+	{1, "I"},
+}
+
+// IntToRomanAdditive returns the roman numeral of n in the additive form without subtractive pairs,
+// e.g. "IIII" instead of "IV" and "VIIII" instead of "IX"
+func IntToRomanAdditive(n int) (string, error) {
+	if n < 1 || n > 3999 {
+		return "", OutOfRange
+	}
+	roman := ""
+	for _, s := range additiveSymbols {
+		for n >= s.value {
+			roman += s.numeral
+			n -= s.value
+		}
+	}
+	return roman, nil
+}
+
+// NumeralsOfLength returns all canonical roman numerals that are exactly n symbols long ordered by value,
+// e.g. "I", "V", "X", "L", "C", "D" and "M" for n == 1
+func NumeralsOfLength(n int) []string {
+	numerals := make([]string, 0)
+	for i := 1; i <= 3999; i++ {
+		if roman, _ := IntToRoman(i); len(roman) == n {
+			numerals = append(numerals, roman)
+		}
+	}
+	return numerals
+}
+
+// WidestInRange returns the number from start to end (inclusive) with the longest canonical roman numeral and the numeral,
+// e.g. to plan the width of a column; the smallest such number is returned in case there are more of them.
+// OutOfRange is returned in case the range is empty or not within 1 to 3999.
+func WidestInRange(start, end int) (n int, roman string, err error) {
+	if start < 1 || end > 3999 || start > end {
+		return 0, "", OutOfRange
+	}
+	for i := start; i <= end; i++ {
+		candidate, _ := IntToRoman(i)
+		if len(candidate) > len(roman) {
+			n, roman = i, candidate
+		}
+	}
+	return n, roman, nil
+}
+
+// checkLetters maps the value of a numeral mod 7 to its check letter
+const checkLetters = "IVXLCDM"
+
+// WithCheckDigit returns the canonical roman numeral of n followed by a check letter,
+// the check letter is checkLetters[n%7], e.g. "MCMXCIVM" for 1994
+func WithCheckDigit(n int) (string, error) {
+	roman, err := IntToRoman(n)
+	if err != nil {
+		return "", err
+	}
+	return roman + checkLetters[n%7:n%7+1], nil
+}
+
+// VerifyCheckDigit returns the value of the numeral s written by WithCheckDigit and whether its check letter matches the value.
+// Invalid is returned in case s without the check letter is not a canonical roman numeral.
+func VerifyCheckDigit(s string) (int, bool, error) {
+	if len(s) < 2 {
+		return -1, false, Invalid
+	}
+	n, err := strictToInt(s[:len(s)-1])
+	if err != nil {
+		return -1, false, err
+	}
+	return n, s[len(s)-1] == checkLetters[n%7], nil
+}
+
+// trimNumeral removes leading and trailing whitespace of the roman numeral, e.g. of a copy-pasted value,
+// Invalid is returned in case the numeral contains whitespace inside, e.g. "X IV"
+func trimNumeral(i string) (string, error) {
+	i = strings.TrimSpace(i)
+	if strings.IndexFunc(i, unicode.IsSpace) != -1 {
+		return "", Invalid
+	}
+	return i, nil
+}
+
+// strictToInt is the same as ToInt, except only canonical roman numerals are accepted
+func strictToInt(i string) (int, error) {
+	n, err := ToInt(i)
+	if err != nil {
+		return -1, err
+	}
+	if canonical, err := IntToRoman(n); err != nil || canonical != i {
+		return -1, Invalid
+	}
+	return n, nil
+}
+
+// romanPlaces lists the symbols for one, five and ten of the decimal places of a roman numeral from the largest,
+// the thousands have no symbol for five and ten
+var romanPlaces = []struct {
+	one, five, ten byte
+}{
+	{'M', 0, 0},
+	{'C', 'D', 'M'},
+	{'X', 'L', 'C'},
+	{'I', 'V', 'X'},
+}
+
+// illegalPosition returns the position of the first symbol that makes s an illegal roman numeral or -1 in case s is legal.
+// Each decimal place must be written either canonically or additively, e.g. "IX" or "VIIII" for 9,
+// so e.g. "XA", "VX", "IM" and "IIIII" are illegal. An empty numeral is illegal at position 0.
+func illegalPosition(s string) int {
+	if s == "" {
+		return 0
+	}
+	j := 0
+	for _, place := range romanPlaces {
+		if place.five != 0 && j+1 < len(s) && s[j] == place.one && (s[j+1] == place.five || s[j+1] == place.ten) {
+			j += 2
+			continue
+		}
+		maxOnes := 3
+		if place.five != 0 {
+			maxOnes = 4
+			if j < len(s) && s[j] == place.five {
+				j++
+			}
+		}
+		for ones := 0; ones < maxOnes && j < len(s) && s[j] == place.one; ones++ {
+			j++
+		}
+	}
+	if j < len(s) {
+		return j
+	}
+	return -1
+}
+
+// checkLegal returns Invalid (wrapped) naming the position of the first offending symbol in case s is not a legal roman numeral
+func checkLegal(s string) error {
+	j := illegalPosition(s)
+	switch {
+	case j == -1:
+		return nil
+	case s == "":
+		return fmt.Errorf("%w: empty numeral", Invalid)
+	case symbolValues[s[j]] == 0:
+		return fmt.Errorf("%w: %q at position %v of %q is not a roman symbol", Invalid, s[j:j+1], j, s)
+	default:
+		return fmt.Errorf("%w: %q at position %v of %q is not allowed there", Invalid, s[j:j+1], j, s)
+	}
+}
+
+// ToIntCanonical is the same as ToInt, except it also returns whether the roman numeral is canonical,
+// e.g. (4, true, nil) for "IV" and (4, false, nil) for "IIII", so the caller can warn about a non-canonical numeral.
+// Invalid is returned (wrapped) for an illegal numeral, e.g. "XA" or "VX". Surrounding whitespace is ignored.
+func ToIntCanonical(s string) (value int, canonical bool, err error) {
+	if s, err = trimNumeral(s); err != nil {
+		return -1, false, err
+	}
+	if err = checkLegal(s); err != nil {
+		return -1, false, err
+	}
+	if value, err = ToInt(s); err != nil {
+		return -1, false, err
+	}
+	roman, err := IntToRoman(value)
+	if err != nil {
+		return -1, false, Invalid
+	}
+	return value, roman == s, nil
+}
+
+// NonCanonical is returned (wrapped) by ToIntsStrict for a legal roman numeral that is not written canonically, e.g. "IIII"
+var NonCanonical = errors.New("not a canonical roman numeral")
+
+// ToIntsStrict converts machine-generated roman numerals to ints, it fails on the first element that is either not a legal roman numeral,
+// e.g. "XA" or "VX" (the error wraps Invalid), or is not written canonically, e.g. "IIII" instead of "IV" (the error wraps NonCanonical).
+// The error names the index and the value of the offending element.
+func ToIntsStrict(in []string) ([]int, error) {
+	out := make([]int, 0, len(in))
+	for i, s := range in {
+		value, canonical, err := ToIntCanonical(s)
+		if err != nil {
+			return nil, fmt.Errorf("element %v %q: %w", i, s, err)
+		}
+		if !canonical {
+			return nil, fmt.Errorf("element %v %q: %w", i, s, NonCanonical)
+		}
+		out = append(out, value)
+	}
+	return out, nil
+}
+
+// symbolValues maps a single roman symbol to its value
+var symbolValues = map[byte]int{'I': 1, 'V': 5, 'X': 10, 'L': 50, 'C': 100, 'D': 500, 'M': 1000}
+
+// Step is a single step of reading a roman numeral symbol by symbol
+type Step struct {
+	// the symbol read in this step, e.g. "X"
+	Symbol string
+	// the value the symbol adds to the total, it is negative in case the symbol is subtracted
+	Value int
+	// explains the step, e.g. "add X=10" or "I before V means subtract 1"
+	Description string
+}
+
+// Explain walks the roman numeral symbol by symbol, e.g. for a classroom visualization, and returns the steps,
+// the values of the steps sum up to the value of the numeral.
+// A symbol is subtracted in case it is followed by a symbol of a larger value.
+// Invalid is returned (wrapped) for an empty numeral and at the first step whose symbol makes the numeral illegal,
+// i.e. it is not a roman symbol, it forms an illegal subtractive pair, e.g. "VX" or "IM", or it is repeated too many times, e.g. "IIIII".
+func Explain(s string) ([]Step, error) {
+	if s == "" {
+		return nil, fmt.Errorf("%w: empty numeral", Invalid)
+	}
+	illegal := illegalPosition(s)
+	steps := make([]Step, 0, len(s))
+	for j := 0; j < len(s); j++ {
+		value, ok := symbolValues[s[j]]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q at step %v of %q is not a roman symbol", Invalid, s[j:j+1], j, s)
+		}
+		if j == illegal {
+			return nil, fmt.Errorf("%w: %q at step %v of %q is not allowed there", Invalid, s[j:j+1], j, s)
+		}
+		if j < len(s)-1 {
+			next, ok := symbolValues[s[j+1]]
+			if ok && value < next {
+				steps = append(steps, Step{s[j : j+1], -value, fmt.Sprintf("%s before %s means subtract %v", s[j:j+1], s[j+1:j+2], value)})
+				continue
+			}
+		}
+		steps = append(steps, Step{s[j : j+1], value, fmt.Sprintf("add %s=%v", s[j:j+1], value)})
+	}
+	return steps, nil
+}
+
+// ToIntSeparated converts a roman numeral whose groups are separated by sep, e.g. "M·CM·XC·IV" as found on inscriptions, to int.
+// The surrounding whitespace and then the separators are removed, so sep may be whitespace too, e.g. " ",
+// and the rest must be a canonical roman numeral, otherwise Invalid is returned.
+func ToIntSeparated(s string, sep string) (int, error) {
+	s = strings.TrimSpace(s)
+	if sep != "" {
+		s = strings.Replace(s, sep, "", -1)
+	}
+	return strictToInt(s)
+}
+
+// apostrophusSymbols lists the symbols of the apostrophus notation of large numbers and the standard symbols,
+// the longer symbols first so that e.g. "CIↃ" (1000) is not read as "C" and "IↃ" (500)
+var apostrophusSymbols = []struct {
+	numeral string
+	value   int
+}{
+	{"CCCIↃↃↃ", 100000},
+	{"IↃↃↃ", 50000},
+	{"CCIↃↃ", 10000},
+	{"IↃↃ", 5000},
+	{"CIↃ", 1000},
+	{"IↃ", 500},
+	{"M", 1000},
+	{"D", 500},
+	{"C", 100},
+	{"L", 50},
+	{"X", 10},
+	{"V", 5},
+	{"I", 1},
+}
+
+// ToIntApostrophus converts a roman numeral that may use the ancient apostrophus notation, e.g. "CIↃ" for 1000
+// and "IↃↃↃ" for 50000, to int. A symbol is subtracted in case it is followed by a symbol of a larger value.
+// Invalid is returned in case s is empty or contains anything else than the symbols.
+func ToIntApostrophus(s string) (int, error) {
+	if s == "" {
+		return -1, Invalid
+	}
+	values := make([]int, 0, len(s))
+	for s != "" {
+		found := false
+		for _, symbol := range apostrophusSymbols {
+			if strings.HasPrefix(s, symbol.numeral) {
+				values = append(values, symbol.value)
+				s = s[len(symbol.numeral):]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return -1, Invalid
+		}
+	}
+	sum := 0
+	for j, value := range values {
+		if j < len(values)-1 && value < values[j+1] {
+			sum -= value
+		} else {
+			sum += value
+		}
+	}
+	return sum, nil
+}
+
+// FromClock converts a roman numeral as written on a clock face to int.
+// Clock faces traditionally use "IIII" for four, all other numbers are canonical roman numerals.
+// Surrounding whitespace is ignored.
+func FromClock(i string) (int, error) {
+	i, err := trimNumeral(i)
+	if err != nil {
+		return -1, err
+	}
+	if i == "IIII" {
+		return 4, nil
+	}
+	return strictToInt(i)
+}
+
+// ToClock returns the roman numeral of n as written on a clock face,
+// i.e. "IIII" for four and the canonical roman numeral otherwise.
+func ToClock(n int) (string, error) {
+	if n == 4 {
+		return "IIII", nil
+	}
+	return IntToRoman(n)
+}
+
+// IntToRomanWithZero is the same as IntToRoman, except zeroGlyph is returned for zero,
+// e.g. "N" (nulla) as used in medieval manuscripts
+func IntToRomanWithZero(n int, zeroGlyph string) (string, error) {
+	if n == 0 {
+		return zeroGlyph, nil
+	}
+	return IntToRoman(n)
+}
+
+// ToIntWithZero is the same as ToInt, except zeroGlyph is converted to zero
+// and Invalid is returned (wrapped) for anything else than zeroGlyph or a legal roman numeral, e.g. "bogus".
+// Surrounding whitespace is ignored.
+func ToIntWithZero(i string, zeroGlyph string) (int, error) {
+	i, err := trimNumeral(i)
+	if err != nil {
+		return -1, err
+	}
+	if zeroGlyph != "" && i == zeroGlyph {
+		return 0, nil
+	}
+	value, _, err := ToIntCanonical(i)
+	return value, err
+}
+
+// Numeral is a canonical roman numeral, e.g. "XIV"
+type Numeral string
+
+// Int returns the value of the numeral or Invalid in case it is not a canonical roman numeral
+func (n Numeral) Int() (int, error) {
+	return strictToInt(string(n))
+}
+
+// IsSuccessor returns true in case b is the numeral immediately following a, i.e. b == a+1
+func IsSuccessor(a, b Numeral) (bool, error) {
+	aInt, err := a.Int()
+	if err != nil {
+		return false, err
+	}
+	bInt, err := b.Int()
+	if err != nil {
+		return false, err
+	}
+	return bInt == aInt+1, nil
+}
+
+// Distance returns the absolute difference of the values of the numerals, e.g. 7 for "X" and "III"
+func Distance(a, b Numeral) (int, error) {
+	aInt, err := a.Int()
+	if err != nil {
+		return 0, err
+	}
+	bInt, err := b.Int()
+	if err != nil {
+		return 0, err
+	}
+	if aInt < bInt {
+		return bInt - aInt, nil
+	}
+	return aInt - bInt, nil
+}
+
+// SymbolEditDistance returns the Levenshtein distance of the canonical symbol strings of the numerals,
+// i.e. the minimal number of symbols to insert, delete or replace to get b from a, e.g. 2 for "IV" and "VI".
+// The numerals are parsed the same way as by ToIntCanonical, so e.g. "IIII" and "IV" have the distance 0.
+func SymbolEditDistance(a, b Numeral) (int, error) {
+	var symbols [2]string
+	for i, n := range []Numeral{a, b} {
+		value, _, err := ToIntCanonical(string(n))
+		if err != nil {
+			return 0, err
+		}
+		if symbols[i], err = IntToRoman(value); err != nil {
+			return 0, err
+		}
+	}
+	// previous[j] is the distance of the first i-1 symbols of a and the first j symbols of b
+	previous := make([]int, len(symbols[1])+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(symbols[0]); i++ {
+		current := make([]int, len(previous))
+		current[0] = i
+		for j := 1; j < len(current); j++ {
+			replace := previous[j-1]
+			if symbols[0][i-1] != symbols[1][j-1] {
+				replace++
+			}
+			current[j] = replace
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous = current
+	}
+	return previous[len(previous)-1], nil
+}
+
+// Year returns the roman numeral of the year of t, e.g. for a "© MMXXIV" footer.
+// OutOfRange is returned for years before 1 and after 3999.
+func Year(t time.Time) (string, error) {
+	return IntToRoman(t.Year())
+}
+
+// Accumulator keeps a running total of numerals, e.g. for a scoreboard
+type Accumulator struct {
+	total int
+}
+
+// Add adds the numeral to the total.
+// An error is returned and the total is not changed in case the numeral is invalid or the total would exceed 3999.
+func (a *Accumulator) Add(n Numeral) error {
+	value, err := n.Int()
+	if err != nil {
+		return err
+	}
+	if a.total+value > 3999 {
+		return OutOfRange
+	}
+	a.total += value
+	return nil
+}
+
+// Total returns the canonical numeral of the total, OutOfRange is returned in case nothing was added yet
+func (a *Accumulator) Total() (Numeral, error) {
+	total, err := IntToRoman(a.total)
+	return Numeral(total), err
+}
+
+// Clamp returns the canonical roman numeral of n for a best-effort display, it never fails.
+// It is lossy: "I" is returned for n < 1 and "MMMCMXCIX" (3999) for n > 3999.
+func Clamp(n int) string {
+	if n < 1 {
+		n = 1
+	} else if n > 3999 {
+		n = 3999
+	}
+	roman, _ := IntToRoman(n)
+	return roman
+}
+
+// MarshalJSON marshals the numeral as a JSON string
+func (n Numeral) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(n))
+}
+
+// UnmarshalJSON unmarshals a JSON string containing a canonical roman numeral
+func (n *Numeral) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return n.UnmarshalText([]byte(s))
+}
+
+// MarshalText marshals the numeral as text, so that Numeral implements encoding.TextMarshaler, e.g. to be used as a map key
+func (n Numeral) MarshalText() ([]byte, error) {
+	return []byte(n), nil
+}
+
+// UnmarshalText unmarshals text containing a canonical roman numeral
+func (n *Numeral) UnmarshalText(text []byte) error {
+	s := string(text)
+	if _, err := Numeral(s).Int(); err != nil {
+		return fmt.Errorf("%q is not a canonical roman numeral: %w", s, err)
+	}
+	*n = Numeral(s)
+	return nil
+}
+
+// String returns the numeral as a string
+func (n Numeral) String() string {
+	return string(n)
+}
+
+// Set converts the roman numeral to the canonical numeral, e.g. "IIII" is set as "IV",
+// so that *Numeral implements flag.Value. An error is returned and the numeral is not changed
+// in case s is not a legal roman numeral, e.g. "XA" or "IM".
+func (n *Numeral) Set(s string) error {
+	value, _, err := ToIntCanonical(s)
+	if err != nil {
+		return err
+	}
+	canonical, err := IntToRoman(value)
+	if err != nil {
+		return Invalid
+	}
+	*n = Numeral(canonical)
+	return nil
+}
+
+// ConvertAll reads whitespace separated roman numerals from r and writes a "numeral=value" line for each of them to w,
+// e.g. "IV=4", or "numeral=invalid" in case it is not a legal roman numeral, e.g. "XA" or "VX",
+// so that a single bad numeral doesn't abort the stream.
+// Only errors of reading r and writing w are returned.
+func ConvertAll(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+	for scanner.Scan() {
+		token := scanner.Text()
+		line := token + "=invalid\n"
+		if value, _, err := ToIntCanonical(token); err == nil {
+			line = fmt.Sprintf("%s=%d\n", token, value)
+		}
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
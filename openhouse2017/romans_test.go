@@ -0,0 +1,627 @@
+package romans
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"flag"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIntToRoman(t *testing.T) {
+	tests := []struct {
+		in   int
+		want string
+	}{
+		{1, "I"},
+		{4, "IV"},
+		{9, "IX"},
+		{14, "XIV"},
+		{1994, "MCMXCIV"},
+		{3999, "MMMCMXCIX"},
+	}
+	for _, tt := range tests {
+		if got, err := IntToRoman(tt.in); err != nil || got != tt.want {
+			t.Errorf("IntToRoman(%v) = (%v, %v), want (%v, %v)", tt.in, got, err, tt.want, nil)
+		}
+	}
+	for _, in := range []int{-1, 0, 4000} {
+		if got, err := IntToRoman(in); err != OutOfRange {
+			t.Errorf("IntToRoman(%v) = (%v, %v), want (%v, %v)", in, got, err, "", OutOfRange)
+		}
+	}
+}
+
+func TestIntToRomanPadded(t *testing.T) {
+	tests := []struct {
+		n, width int
+		want     string
+	}{
+		{4, 5, "   IV"},
+		{4, 2, "IV"},
+		{1994, 8, " MCMXCIV"},
+	}
+	for _, tt := range tests {
+		if got, err := IntToRomanPadded(tt.n, tt.width); err != nil || got != tt.want {
+			t.Errorf("IntToRomanPadded(%v, %v) = (%q, %v), want (%q, %v)", tt.n, tt.width, got, err, tt.want, nil)
+		}
+	}
+	if got, err := IntToRomanPadded(1994, 5); err == nil {
+		t.Errorf("IntToRomanPadded(%v, %v) = (%q, %v), want an error", 1994, 5, got, err)
+	}
+	if got, err := IntToRomanPadded(4000, 20); err != OutOfRange {
+		t.Errorf("IntToRomanPadded(%v, %v) = (%q, %v), want (%q, %v)", 4000, 20, got, err, "", OutOfRange)
+	}
+}
+
+func TestSymbolFrequency(t *testing.T) {
+	tests := []struct {
+		in   int
+		want map[rune]int
+	}{
+		{38, map[rune]int{'X': 3, 'V': 1, 'I': 3}},
+		{3888, map[rune]int{'M': 3, 'D': 1, 'C': 3, 'L': 1, 'X': 3, 'V': 1, 'I': 3}},
+	}
+	for _, tt := range tests {
+		if got, err := SymbolFrequency(tt.in); err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SymbolFrequency(%v) = (%v, %v), want (%v, %v)", tt.in, got, err, tt.want, nil)
+		}
+	}
+	if got, err := SymbolFrequency(0); err != OutOfRange {
+		t.Errorf("SymbolFrequency(%v) = (%v, %v), want (%v, %v)", 0, got, err, nil, OutOfRange)
+	}
+}
+
+func TestWhitespace(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{" XIV ", 14},
+		{"\tIX\n", 9},
+		{"\u00a0IV\u00a0", 4},
+	}
+	for _, tt := range tests {
+		if got, err := ToInt(tt.in); err != nil || got != tt.want {
+			t.Errorf("ToInt(%q) = (%v, %v), want (%v, %v)", tt.in, got, err, tt.want, nil)
+		}
+		if got, _, err := ToIntCanonical(tt.in); err != nil || got != tt.want {
+			t.Errorf("ToIntCanonical(%q) = (%v, %v), want (%v, %v)", tt.in, got, err, tt.want, nil)
+		}
+		if got, err := ToIntWithZero(tt.in, "N"); err != nil || got != tt.want {
+			t.Errorf("ToIntWithZero(%q, %q) = (%v, %v), want (%v, %v)", tt.in, "N", got, err, tt.want, nil)
+		}
+		if got, err := FromClock(tt.in); err != nil || got != tt.want {
+			t.Errorf("FromClock(%q) = (%v, %v), want (%v, %v)", tt.in, got, err, tt.want, nil)
+		}
+		if got, err := ToIntSeparated(tt.in, "·"); err != nil || got != tt.want {
+			t.Errorf("ToIntSeparated(%q, %q) = (%v, %v), want (%v, %v)", tt.in, "·", got, err, tt.want, nil)
+		}
+	}
+	if _, canonical, err := ToIntCanonical(" IV "); err != nil || !canonical {
+		t.Errorf("ToIntCanonical(%q) = (_, %v, %v), want (_, %v, %v)", " IV ", canonical, err, true, nil)
+	}
+	for _, in := range []string{"X IV", " X\tIV "} {
+		if got, err := ToInt(in); err != Invalid {
+			t.Errorf("ToInt(%q) = (%v, %v), want (%v, %v)", in, got, err, -1, Invalid)
+		}
+		if got, _, err := ToIntCanonical(in); err != Invalid {
+			t.Errorf("ToIntCanonical(%q) = (%v, %v), want (%v, %v)", in, got, err, -1, Invalid)
+		}
+		if got, err := FromClock(in); err != Invalid {
+			t.Errorf("FromClock(%q) = (%v, %v), want (%v, %v)", in, got, err, -1, Invalid)
+		}
+	}
+}
+
+func TestIntToRomanAdditive(t *testing.T) {
+	tests := []struct {
+		in   int
+		want string
+	}{
+		{1, "I"},
+		{4, "IIII"},
+		{9, "VIIII"},
+		{14, "XIIII"},
+		{1990, "MDCCCCLXXXX"},
+		{3999, "MMMDCCCCLXXXXVIIII"},
+	}
+	for _, tt := range tests {
+		if got, err := IntToRomanAdditive(tt.in); err != nil || got != tt.want {
+			t.Errorf("IntToRomanAdditive(%v) = (%v, %v), want (%v, %v)", tt.in, got, err, tt.want, nil)
+		}
+	}
+	for _, in := range []int{-1, 0, 4000} {
+		if got, err := IntToRomanAdditive(in); err != OutOfRange {
+			t.Errorf("IntToRomanAdditive(%v) = (%v, %v), want (%v, %v)", in, got, err, "", OutOfRange)
+		}
+	}
+}
+
+func TestNumeralsOfLength(t *testing.T) {
+	if got, want := NumeralsOfLength(1), []string{"I", "V", "X", "L", "C", "D", "M"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NumeralsOfLength(1) = %v, want %v", got, want)
+	}
+	// MMMDCCCLXXXVIII (3888) is the longest canonical numeral
+	if got, want := NumeralsOfLength(15), []string{"MMMDCCCLXXXVIII"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NumeralsOfLength(15) = %v, want %v", got, want)
+	}
+	for _, n := range []int{0, 16} {
+		if got := NumeralsOfLength(n); len(got) != 0 {
+			t.Errorf("NumeralsOfLength(%v) = %v, want %v", n, got, []string{})
+		}
+	}
+}
+
+func TestWidestInRange(t *testing.T) {
+	tests := []struct {
+		start, end int
+		wantN      int
+		wantRoman  string
+	}{
+		{1, 10, 8, "VIII"},
+		{5, 5, 5, "V"},
+		{1, 3999, 3888, "MMMDCCCLXXXVIII"},
+	}
+	for _, tt := range tests {
+		if n, roman, err := WidestInRange(tt.start, tt.end); err != nil || n != tt.wantN || roman != tt.wantRoman {
+			t.Errorf("WidestInRange(%v, %v) = (%v, %q, %v), want (%v, %q, %v)", tt.start, tt.end, n, roman, err, tt.wantN, tt.wantRoman, nil)
+		}
+	}
+	for _, r := range [][2]int{{0, 10}, {1, 4000}, {10, 1}} {
+		if n, roman, err := WidestInRange(r[0], r[1]); err != OutOfRange {
+			t.Errorf("WidestInRange(%v, %v) = (%v, %q, %v), want (%v, %q, %v)", r[0], r[1], n, roman, err, 0, "", OutOfRange)
+		}
+	}
+}
+
+func TestToIntCanonical(t *testing.T) {
+	tests := []struct {
+		in            string
+		wantValue     int
+		wantCanonical bool
+	}{
+		{"IV", 4, true},
+		{"IIII", 4, false},
+		{"MCMXCIV", 1994, true},
+		{"MDCCCCLXXXX", 1990, false},
+	}
+	for _, tt := range tests {
+		if value, canonical, err := ToIntCanonical(tt.in); err != nil || value != tt.wantValue || canonical != tt.wantCanonical {
+			t.Errorf("ToIntCanonical(%q) = (%v, %v, %v), want (%v, %v, %v)", tt.in, value, canonical, err, tt.wantValue, tt.wantCanonical, nil)
+		}
+	}
+	for _, in := range []string{"bogus", "a", "", "XA", "XbogusV", "VX", "IM", "IIIII", "VV", "XCX", "MMMM"} {
+		if value, canonical, err := ToIntCanonical(in); !errors.Is(err, Invalid) {
+			t.Errorf("ToIntCanonical(%q) = (%v, %v, %v), want (%v, %v, %v)", in, value, canonical, err, -1, false, Invalid)
+		}
+	}
+}
+
+func TestToIntsStrict(t *testing.T) {
+	in := []string{"I", "IV", "MCMXCIV"}
+	want := []int{1, 4, 1994}
+	if got, err := ToIntsStrict(in); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ToIntsStrict(%q) = (%v, %v), want (%v, %v)", in, got, err, want, nil)
+	}
+
+	in = []string{"I", "IIII", "V"}
+	got, err := ToIntsStrict(in)
+	if !errors.Is(err, NonCanonical) || errors.Is(err, Invalid) || !strings.Contains(err.Error(), `"IIII"`) {
+		t.Errorf("ToIntsStrict(%q) = (%v, %v), want a non-canonical error naming %q", in, got, err, "IIII")
+	}
+
+	for _, invalid := range []string{"bogus", "XA", "VX"} {
+		in = []string{"I", invalid, "IIII"}
+		got, err = ToIntsStrict(in)
+		if !errors.Is(err, Invalid) || errors.Is(err, NonCanonical) || !strings.Contains(err.Error(), strconv.Quote(invalid)) {
+			t.Errorf("ToIntsStrict(%q) = (%v, %v), want an invalid error naming %q", in, got, err, invalid)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	steps, err := Explain("MCMXCIV")
+	if err != nil {
+		t.Fatalf("Explain(%q) = (%v, %v), want no error", "MCMXCIV", steps, err)
+	}
+	want := []Step{
+		{"M", 1000, "add M=1000"},
+		{"C", -100, "C before M means subtract 100"},
+		{"M", 1000, "add M=1000"},
+		{"X", -10, "X before C means subtract 10"},
+		{"C", 100, "add C=100"},
+		{"I", -1, "I before V means subtract 1"},
+		{"V", 5, "add V=5"},
+	}
+	if !reflect.DeepEqual(steps, want) {
+		t.Errorf("Explain(%q) = %v, want %v", "MCMXCIV", steps, want)
+	}
+	sum := 0
+	for _, step := range steps {
+		sum += step.Value
+	}
+	if sum != 1994 {
+		t.Errorf("Explain(%q) steps sum up to %v, want %v", "MCMXCIV", sum, 1994)
+	}
+
+	for _, in := range []string{"", "XIa", "bogus"} {
+		if steps, err := Explain(in); !errors.Is(err, Invalid) {
+			t.Errorf("Explain(%q) = (%v, %v), want (%v, %v)", in, steps, err, nil, Invalid)
+		}
+	}
+
+	illegal := []struct {
+		in   string
+		step string
+	}{
+		{"VX", "step 1"},
+		{"IM", "step 1"},
+		{"IIIIIIIIII", "step 4"},
+		{"MCMXCIVI", "step 7"},
+	}
+	for _, tt := range illegal {
+		if steps, err := Explain(tt.in); !errors.Is(err, Invalid) || !strings.Contains(err.Error(), tt.step) {
+			t.Errorf("Explain(%q) = (%v, %v), want an invalid error naming %q", tt.in, steps, err, tt.step)
+		}
+	}
+}
+
+func TestCheckDigit(t *testing.T) {
+	for _, n := range []int{1, 7, 1994, 3999} {
+		s, err := WithCheckDigit(n)
+		if err != nil {
+			t.Errorf("WithCheckDigit(%v) = (%q, %v), want no error", n, s, err)
+			continue
+		}
+		if got, ok, err := VerifyCheckDigit(s); err != nil || got != n || !ok {
+			t.Errorf("VerifyCheckDigit(%q) = (%v, %v, %v), want (%v, %v, %v)", s, got, ok, err, n, true, nil)
+		}
+	}
+	if got, err := WithCheckDigit(1994); err != nil || got != "MCMXCIVM" {
+		t.Errorf("WithCheckDigit(1994) = (%q, %v), want (%q, %v)", got, err, "MCMXCIVM", nil)
+	}
+
+	// the numeral was tampered with from MCMXCIV to MCMXCV
+	if got, ok, err := VerifyCheckDigit("MCMXCVM"); err != nil || got != 1995 || ok {
+		t.Errorf("VerifyCheckDigit(%q) = (%v, %v, %v), want (%v, %v, %v)", "MCMXCVM", got, ok, err, 1995, false, nil)
+	}
+	for _, in := range []string{"", "I", "IIIIV", "bogusM"} {
+		if got, ok, err := VerifyCheckDigit(in); err == nil {
+			t.Errorf("VerifyCheckDigit(%q) = (%v, %v, %v), want an error", in, got, ok, err)
+		}
+	}
+	if got, err := WithCheckDigit(0); err != OutOfRange {
+		t.Errorf("WithCheckDigit(0) = (%q, %v), want (%q, %v)", got, err, "", OutOfRange)
+	}
+}
+
+func TestToIntSeparated(t *testing.T) {
+	tests := []struct {
+		in, sep string
+		want    int
+	}{
+		{"M.CM.XC.IV", ".", 1994},
+		{"M·CM·XC·IV", "·", 1994},
+		{"XIV", "", 14},
+		{"M CM XC IV", " ", 1994},
+		{" M CM XC IV\n", " ", 1994},
+	}
+	for _, tt := range tests {
+		if got, err := ToIntSeparated(tt.in, tt.sep); err != nil || got != tt.want {
+			t.Errorf("ToIntSeparated(%q, %q) = (%v, %v), want (%v, %v)", tt.in, tt.sep, got, err, tt.want, nil)
+		}
+	}
+	for _, tt := range []struct{ in, sep string }{
+		{"I.I.I.I", "."},
+		{"M.CM", "·"},
+		{"...", "."},
+		{"M CM", "·"},
+		{"M\tCM", " "},
+	} {
+		if got, err := ToIntSeparated(tt.in, tt.sep); err != Invalid {
+			t.Errorf("ToIntSeparated(%q, %q) = (%v, %v), want (%v, %v)", tt.in, tt.sep, got, err, -1, Invalid)
+		}
+	}
+}
+
+func TestToIntApostrophus(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"IↃ", 500},
+		{"CIↃ", 1000},
+		{"IↃↃↃ", 50000},
+		{"CCIↃↃCIↃCIↃ", 12000},
+		{"CIↃIↃXIV", 1514},
+		{"MCMXCIV", 1994},
+	}
+	for _, tt := range tests {
+		if got, err := ToIntApostrophus(tt.in); err != nil || got != tt.want {
+			t.Errorf("ToIntApostrophus(%q) = (%v, %v), want (%v, %v)", tt.in, got, err, tt.want, nil)
+		}
+	}
+	for _, in := range []string{"", "Ↄ", "CIↃa"} {
+		if got, err := ToIntApostrophus(in); err != Invalid {
+			t.Errorf("ToIntApostrophus(%q) = (%v, %v), want (%v, %v)", in, got, err, -1, Invalid)
+		}
+	}
+}
+
+func TestClock(t *testing.T) {
+	tests := []struct {
+		n     int
+		clock string
+	}{
+		{1, "I"},
+		{4, "IIII"},
+		{9, "IX"},
+		{12, "XII"},
+	}
+	for _, tt := range tests {
+		if got, err := FromClock(tt.clock); err != nil || got != tt.n {
+			t.Errorf("FromClock(%q) = (%v, %v), want (%v, %v)", tt.clock, got, err, tt.n, nil)
+		}
+		if got, err := ToClock(tt.n); err != nil || got != tt.clock {
+			t.Errorf("ToClock(%v) = (%q, %v), want (%q, %v)", tt.n, got, err, tt.clock, nil)
+		}
+	}
+	for _, in := range []string{"IV", "XIV"} {
+		if _, err := FromClock(in); err != nil {
+			t.Errorf("FromClock(%q) returned unexpected error: %v", in, err)
+		}
+	}
+	for _, in := range []string{"VIIII", "IIIII", "bogus", ""} {
+		if got, err := FromClock(in); err == nil {
+			t.Errorf("FromClock(%q) = (%v, %v), want an error", in, got, err)
+		}
+	}
+}
+
+func TestZero(t *testing.T) {
+	tests := []struct {
+		in   int
+		want string
+	}{
+		{0, "N"},
+		{1, "I"},
+		{2024, "MMXXIV"},
+	}
+	for _, tt := range tests {
+		got, err := IntToRomanWithZero(tt.in, "N")
+		if err != nil || got != tt.want {
+			t.Errorf("IntToRomanWithZero(%v, %q) = (%q, %v), want (%q, %v)", tt.in, "N", got, err, tt.want, nil)
+		}
+		if back, err := ToIntWithZero(got, "N"); err != nil || back != tt.in {
+			t.Errorf("ToIntWithZero(%q, %q) = (%v, %v), want (%v, %v)", got, "N", back, err, tt.in, nil)
+		}
+	}
+	for _, in := range []string{"bogus", "", "XA", "NN"} {
+		if got, err := ToIntWithZero(in, "N"); !errors.Is(err, Invalid) {
+			t.Errorf("ToIntWithZero(%q, %q) = (%v, %v), want (%v, %v)", in, "N", got, err, -1, Invalid)
+		}
+	}
+	for _, in := range []int{-1, 4000} {
+		if got, err := IntToRomanWithZero(in, "N"); err != OutOfRange {
+			t.Errorf("IntToRomanWithZero(%v, %q) = (%q, %v), want (%q, %v)", in, "N", got, err, "", OutOfRange)
+		}
+	}
+}
+
+func TestIsSuccessor(t *testing.T) {
+	tests := []struct {
+		a, b Numeral
+		want bool
+	}{
+		{"III", "IV", true},
+		{"III", "V", false},
+		{"IV", "III", false},
+		{"XXXIX", "XL", true},
+	}
+	for _, tt := range tests {
+		if got, err := IsSuccessor(tt.a, tt.b); err != nil || got != tt.want {
+			t.Errorf("IsSuccessor(%q, %q) = (%v, %v), want (%v, %v)", tt.a, tt.b, got, err, tt.want, nil)
+		}
+	}
+	for _, tt := range []struct{ a, b Numeral }{{"III", "bogus"}, {"IIII", "V"}} {
+		if got, err := IsSuccessor(tt.a, tt.b); err == nil {
+			t.Errorf("IsSuccessor(%q, %q) = (%v, %v), want an error", tt.a, tt.b, got, err)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b Numeral
+		want int
+	}{
+		{"X", "III", 7},
+		{"III", "X", 7},
+		{"IV", "IV", 0},
+	}
+	for _, tt := range tests {
+		if got, err := Distance(tt.a, tt.b); err != nil || got != tt.want {
+			t.Errorf("Distance(%q, %q) = (%v, %v), want (%v, %v)", tt.a, tt.b, got, err, tt.want, nil)
+		}
+	}
+	for _, tt := range []struct{ a, b Numeral }{{"X", "bogus"}, {"bogus", "X"}} {
+		if got, err := Distance(tt.a, tt.b); !errors.Is(err, Invalid) {
+			t.Errorf("Distance(%q, %q) = (%v, %v), want (%v, %v)", tt.a, tt.b, got, err, 0, Invalid)
+		}
+	}
+}
+
+func TestSymbolEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b Numeral
+		want int
+	}{
+		{"IV", "VI", 2},
+		{"III", "III", 0},
+		{"IX", "X", 1},
+		{"MCMXCIV", "I", 6},
+		{"IIII", "IV", 0},
+		{"VIIII", "X", 1},
+	}
+	for _, tt := range tests {
+		if got, err := SymbolEditDistance(tt.a, tt.b); err != nil || got != tt.want {
+			t.Errorf("SymbolEditDistance(%q, %q) = (%v, %v), want (%v, %v)", tt.a, tt.b, got, err, tt.want, nil)
+		}
+	}
+	for _, tt := range []struct{ a, b Numeral }{{"IV", "bogus"}, {"VX", "V"}} {
+		if got, err := SymbolEditDistance(tt.a, tt.b); !errors.Is(err, Invalid) {
+			t.Errorf("SymbolEditDistance(%q, %q) = (%v, %v), want (%v, %v)", tt.a, tt.b, got, err, 0, Invalid)
+		}
+	}
+}
+
+func TestYear(t *testing.T) {
+	tests := []struct {
+		year int
+		want string
+	}{
+		{1, "I"},
+		{2024, "MMXXIV"},
+		{3999, "MMMCMXCIX"},
+	}
+	for _, tt := range tests {
+		in := time.Date(tt.year, time.June, 1, 0, 0, 0, 0, time.UTC)
+		if got, err := Year(in); err != nil || got != tt.want {
+			t.Errorf("Year(%v) = (%q, %v), want (%q, %v)", in, got, err, tt.want, nil)
+		}
+	}
+	for _, year := range []int{0, 4000} {
+		in := time.Date(year, time.June, 1, 0, 0, 0, 0, time.UTC)
+		if got, err := Year(in); err != OutOfRange {
+			t.Errorf("Year(%v) = (%q, %v), want (%q, %v)", in, got, err, "", OutOfRange)
+		}
+	}
+}
+
+func TestAccumulator(t *testing.T) {
+	var a Accumulator
+	if got, err := a.Total(); err != OutOfRange {
+		t.Errorf("Total() = (%q, %v), want (%q, %v)", got, err, "", OutOfRange)
+	}
+	for _, n := range []Numeral{"V", "V", "III"} {
+		if err := a.Add(n); err != nil {
+			t.Errorf("Add(%q) returned unexpected error: %v", n, err)
+		}
+	}
+	if got, err := a.Total(); err != nil || got != "XIII" {
+		t.Errorf("Total() = (%q, %v), want (%q, %v)", got, err, "XIII", nil)
+	}
+	for _, n := range []Numeral{"bogus", "MMMCMXCIX"} {
+		if err := a.Add(n); err == nil {
+			t.Errorf("Add(%q) returned no error, want an error", n)
+		}
+	}
+	if got, err := a.Total(); err != nil || got != "XIII" {
+		t.Errorf("Total() = (%q, %v), want (%q, %v)", got, err, "XIII", nil)
+	}
+}
+
+func TestClamp(t *testing.T) {
+	tests := []struct {
+		in   int
+		want string
+	}{
+		{-5, "I"},
+		{0, "I"},
+		{1, "I"},
+		{2024, "MMXXIV"},
+		{3999, "MMMCMXCIX"},
+		{5000, "MMMCMXCIX"},
+	}
+	for _, tt := range tests {
+		if got := Clamp(tt.in); got != tt.want {
+			t.Errorf("Clamp(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNumeralJSON(t *testing.T) {
+	type page struct {
+		N Numeral
+	}
+	data, err := json.Marshal(page{"XIV"})
+	if err != nil || string(data) != `{"N":"XIV"}` {
+		t.Errorf("json.Marshal(%v) = (%s, %v), want (%s, %v)", page{"XIV"}, data, err, `{"N":"XIV"}`, nil)
+	}
+	var got page
+	if err := json.Unmarshal(data, &got); err != nil || got.N != "XIV" {
+		t.Errorf("json.Unmarshal(%s) = (%v, %v), want (%v, %v)", data, got, err, page{"XIV"}, nil)
+	}
+
+	for _, in := range []string{`{"N":"IIII"}`, `{"N":"bogus"}`, `{"N":14}`} {
+		var got page
+		if err := json.Unmarshal([]byte(in), &got); err == nil {
+			t.Errorf("json.Unmarshal(%s) = (%v, %v), want an error", in, got, err)
+		}
+	}
+}
+
+func TestNumeralFlag(t *testing.T) {
+	var n Numeral
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Var(&n, "page", "page in roman numerals")
+	if err := flags.Parse([]string{"-page", "XIV"}); err != nil || n != "XIV" {
+		t.Errorf("Parse(-page XIV) = (%q, %v), want (%q, %v)", n, err, "XIV", nil)
+	}
+	if err := n.Set("IIII"); err != nil || n.String() != "IV" {
+		t.Errorf("Set(%q) = (%q, %v), want (%q, %v)", "IIII", n, err, "IV", nil)
+	}
+	for _, in := range []string{"bogus", "XA", "IM"} {
+		if err := n.Set(in); !errors.Is(err, Invalid) || n != "IV" {
+			t.Errorf("Set(%q) = (%q, %v), want (%q, %v)", in, n, err, "IV", Invalid)
+		}
+	}
+}
+
+func TestConvertAll(t *testing.T) {
+	var out strings.Builder
+	in := "I bad\n  IV XA VX IIII"
+	if err := ConvertAll(strings.NewReader(in), &out); err != nil {
+		t.Fatalf("ConvertAll(%q) returned error %v", in, err)
+	}
+	if want := "I=1\nbad=invalid\nIV=4\nXA=invalid\nVX=invalid\nIIII=4\n"; out.String() != want {
+		t.Errorf("ConvertAll(%q) wrote %q, want %q", in, out.String(), want)
+	}
+}
+
+func TestNumeralText(t *testing.T) {
+	roundTrip := func(m encoding.TextMarshaler, u encoding.TextUnmarshaler) error {
+		text, err := m.MarshalText()
+		if err != nil {
+			return err
+		}
+		return u.UnmarshalText(text)
+	}
+	var got Numeral
+	if err := roundTrip(Numeral("XIV"), &got); err != nil || got != "XIV" {
+		t.Errorf("round trip of %q = (%q, %v), want (%q, %v)", "XIV", got, err, "XIV", nil)
+	}
+
+	// map keys are marshaled as text
+	pages := map[Numeral]int{"XIV": 14, "IX": 9}
+	data, err := json.Marshal(pages)
+	if err != nil || string(data) != `{"IX":9,"XIV":14}` {
+		t.Errorf("json.Marshal(%v) = (%s, %v), want (%s, %v)", pages, data, err, `{"IX":9,"XIV":14}`, nil)
+	}
+	var gotPages map[Numeral]int
+	if err := json.Unmarshal(data, &gotPages); err != nil || !reflect.DeepEqual(gotPages, pages) {
+		t.Errorf("json.Unmarshal(%s) = (%v, %v), want (%v, %v)", data, gotPages, err, pages, nil)
+	}
+
+	for _, in := range []string{"IIII", "bogus", ""} {
+		got := Numeral("V")
+		if err := got.UnmarshalText([]byte(in)); err == nil || got != "V" {
+			t.Errorf("UnmarshalText(%q) = (%q, %v), want (%q, an error)", in, got, err, "V")
+		}
+	}
+}
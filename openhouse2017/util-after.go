@@ -22,14 +22,24 @@ import (
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/kubernetes/pkg/api/v1"
+	batchv1 "k8s.io/kubernetes/pkg/apis/batch/v1"
 	"k8s.io/kubernetes/pkg/client/clientset_generated/clientset"
 
+	"container/list"
+	"context"
+	stderrors "errors"
 	"hash/fnv"
+	"math"
 	"math/rand"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
 
 	"github.com/golang/glog"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -41,6 +51,9 @@ import (
 
 type RecycleEventRecorder func(eventtype, message string)
 
+// RecyclerPVLabel is a recycler pod label that ties the pod to the PV being recycled, its value is the PV name
+const RecyclerPVLabel = "recycler.volume.kubernetes.io/pv-name"
+
 // RecycleVolumeByWatchingPodUntilCompletion is intended for use with volume
 // Recyclers. This function will save the given Pod to the API and watch it
 // until it completes, fails, or the pod's ActiveDeadlineSeconds is exceeded,
@@ -51,87 +64,146 @@ type RecycleEventRecorder func(eventtype, message string)
 // function assumes it's an older instance of the recycler pod and watches
 // this old pod instead of starting a new one.
 //
-//  pod - the pod designed by a volume plugin to recycle the volume. pod.Name
-//        will be overwritten with unique name based on PV.Name.
+//  pod - the pod designed by a volume plugin to recycle the volume. In case
+//        pod.Name is empty, it will be set to unique name based on PV.Name.
+//        A non-empty pod.Name is kept, but it must be unique for the PV
+//        across all namespaces and controllers, because an existing pod with
+//        the same namespace+name is adopted as described above.
+//        The pod gets the RecyclerPVLabel label with pvName as its value,
+//        the old pod is adopted only if it carries the same label value.
+//        pod.Spec.RestartPolicy must be Never or OnFailure, because a pod
+//        that is always restarted never completes.
+//        The pod must have exactly one container.
 //	client - kube client for API operations.
 func RecycleVolumeByWatchingPodUntilCompletion(pvName string, pod *v1.Pod, kubeClient clientset.Interface, recorder RecycleEventRecorder) error {
+	_, err := internalRecycleVolumeByWatchingPodUntilCompletion(pvName, pod, newRecyclerClient(kubeClient, recorder))
+	return err
+}
+
+// RecycleStats summarizes the watch events processed while recycling a volume
+type RecycleStats struct {
+	// number of the recycler pod (or job) watch events per event type (Added, Modified, Deleted, Error)
+	PodEvents map[watch.EventType]int
+	// phase of the recycler pod in the last received pod watch event, it is empty in case a job recycles the volume
+	FinalPhase v1.PodPhase
+}
+
+// RecycleVolumeByWatchingPodUntilCompletionStats is the same as
+// RecycleVolumeByWatchingPodUntilCompletion, except it also returns statistics
+// of the watch events processed during the recycling. The statistics are
+// returned even in case the recycling failed.
+func RecycleVolumeByWatchingPodUntilCompletionStats(pvName string, pod *v1.Pod, kubeClient clientset.Interface, recorder RecycleEventRecorder) (*RecycleStats, error) {
 	return internalRecycleVolumeByWatchingPodUntilCompletion(pvName, pod, newRecyclerClient(kubeClient, recorder))
 }
 
 // same as above func comments, except 'recyclerClient' is a narrower pod API
 // interface to ease testing
-func internalRecycleVolumeByWatchingPodUntilCompletion(pvName string, pod *v1.Pod, recyclerClient recyclerClient) error {
+func internalRecycleVolumeByWatchingPodUntilCompletion(pvName string, pod *v1.Pod, recyclerClient recyclerClient) (*RecycleStats, error) {
+	return internalRecycleWithDeadline(pvName, pod, recyclerClient, time.Duration(math.MaxInt64), func(elapsed, total time.Duration) {}, DefaultRecycleEventDedupWindow)
+}
+
+// recycleTicks is the number of times onTick is called during the whole timeout of internalRecycleWithDeadline
+const recycleTicks = 10
+
+// DefaultRecycleEventDedupWindow is the time window in which the recycler events with the same type and message
+// are forwarded to the PV only once, so that the PV is not spammed when the API server resends the events.
+const DefaultRecycleEventDedupWindow = 10 * time.Second
+
+// recycleEventKey identifies repeated recycler events
+type recycleEventKey struct {
+	eventtype, message string
+}
+
+// recycleEventDeduper decides whether a recycler event is forwarded to the PV
+type recycleEventDeduper struct {
+	window time.Duration
+	now    func() time.Time
+	// the time each distinct event was last forwarded
+	forwarded map[recycleEventKey]time.Time
+}
+
+func newRecycleEventDeduper(window time.Duration) *recycleEventDeduper {
+	return &recycleEventDeduper{window: window, now: time.Now, forwarded: make(map[recycleEventKey]time.Time)}
+}
+
+// shouldForward returns false in case the same event was forwarded within the window, otherwise the event is recorded as forwarded
+func (d *recycleEventDeduper) shouldForward(eventtype, message string) bool {
+	key := recycleEventKey{eventtype, message}
+	now := d.now()
+	if last, ok := d.forwarded[key]; ok && now.Sub(last) < d.window {
+		glog.V(5).Infof("recycler event %s %q was already forwarded at %v", eventtype, message, last)
+		return false
+	}
+	d.forwarded[key] = now
+	return true
+}
+
+// internalRecycleWithDeadline is the same as internalRecycleVolumeByWatchingPodUntilCompletion,
+// except it gives up watching the recycler pod with an error after the timeout and it calls
+// onTick periodically (recycleTicks times per the timeout) with the time elapsed since the recycling
+// started and the timeout, so that a caller can report the progress of the recycling.
+// The timeout must be positive. The recycler events with the same type and message are forwarded
+// to the PV only once within the eventDedupWindow, zero means all events are forwarded.
+func internalRecycleWithDeadline(pvName string, pod *v1.Pod, recyclerClient recyclerClient, timeout time.Duration, onTick func(elapsed, total time.Duration), eventDedupWindow time.Duration) (*RecycleStats, error) {
 	glog.V(5).Infof("creating recycler pod for volume %s\n", pod.Name)
 
 	// Generate unique name for the recycler pod - we need to get "already
 	// exists" error when a previous controller has already started recycling
 	// the volume. Here we assume that pv.Name is already unique.
-	pod.Name = "recycler-for-" + pvName
+	// A name set by the caller is kept, the caller is responsible for its
+	// uniqueness.
+	if pod.Name == "" {
+		pod.Name = "recycler-for-" + pvName
+	}
 	pod.GenerateName = ""
-
-	stopChannel := make(chan struct{})
-	defer close(stopChannel)
-	podCh, err := recyclerClient.WatchPod(pod.Name, pod.Namespace, stopChannel)
-	if err != nil {
-		glog.V(4).Infof("cannot start watcher for pod %s/%s: %v", pod.Namespace, pod.Name, err)
-		return err
+	// The label ties the pod to the PV, so that a foreign pod with the same name is never adopted.
+	if pod.Labels == nil {
+		pod.Labels = make(map[string]string)
 	}
+	pod.Labels[RecyclerPVLabel] = pvName
+
+	workload := &podRecycleWorkload{pvName: pvName, pod: pod, client: recyclerClient}
+	stats, err := recycleWorkloadWithDeadline(workload, timeout, onTick, eventDedupWindow)
+	stats.FinalPhase = workload.finalPhase
+	return stats, err
+}
 
-	// Start the pod
-	_, err = recyclerClient.CreatePod(pod)
+// checkOldRecyclerPod returns an error in case the already existing pod with the same namespace+name as the pod
+// does not carry the RecyclerPVLabel label with the value pvName, i.e. the existing pod is not an older instance
+// of the recycler pod for the same PV. A pod without the label is foreign.
+func checkOldRecyclerPod(pvName string, pod *v1.Pod, recyclerClient recyclerClient) error {
+	oldPod, err := recyclerClient.GetPod(pod.Name, pod.Namespace)
 	if err != nil {
-		if errors.IsAlreadyExists(err) {
-			glog.V(5).Infof("old recycler pod %q found for volume", pod.Name)
-		} else {
-			return fmt.Errorf("unexpected error creating recycler pod:  %+v\n", err)
-		}
+		return fmt.Errorf("cannot get old recycler pod %s/%s: %v", pod.Namespace, pod.Name, err)
 	}
-	defer func(pod *v1.Pod) {
-		glog.V(2).Infof("deleting recycler pod %s/%s", pod.Namespace, pod.Name)
-		if err := recyclerClient.DeletePod(pod.Name, pod.Namespace); err != nil {
-			glog.Errorf("failed to delete recycler pod %s/%s: %v", pod.Namespace, pod.Name, err)
-		}
-	}(pod)
-
-	// Now only the old pod or the new pod run. Watch it until it finishes
-	// and send all events on the pod to the PV
-	for {
-		event := <-podCh
-		switch event.Object.(type) {
-		case *v1.Pod:
-			// POD changed
-			pod := event.Object.(*v1.Pod)
-			glog.V(4).Infof("recycler pod update received: %s %s/%s %s", event.Type, pod.Namespace, pod.Name, pod.Status.Phase)
-			switch event.Type {
-			case watch.Added, watch.Modified:
-				if pod.Status.Phase == v1.PodSucceeded {
-					// Recycle succeeded.
-					return nil
-				}
-				if pod.Status.Phase == v1.PodFailed {
-					if pod.Status.Message != "" {
-						return fmt.Errorf(pod.Status.Message)
-					} else {
-						return fmt.Errorf("pod failed, pod.Status.Message unknown.")
-					}
-				}
+	return checkRecyclerPVLabel("pod", &oldPod.ObjectMeta, pvName)
+}
 
-			case watch.Deleted:
-				return fmt.Errorf("recycler pod was deleted")
+// checkRecyclerPVLabel returns an error in case the existing recycler workload of the kind does not carry
+// the RecyclerPVLabel label with the value pvName
+func checkRecyclerPVLabel(kind string, existing *metav1.ObjectMeta, pvName string) error {
+	if existingPvName, ok := existing.Labels[RecyclerPVLabel]; !ok || existingPvName != pvName {
+		return fmt.Errorf("%s %s/%s already exists, but it is not a recycler %s of volume %q", kind, existing.Namespace, existing.Name, kind, pvName)
+	}
+	return nil
+}
 
-			case watch.Error:
-				return fmt.Errorf("recycler pod watcher failed")
-			}
+// validateRecyclerPod returns an error in case the recycler pod would never complete
+// or it doesn't have exactly one container
+func validateRecyclerPod(pod *v1.Pod) error {
+	return validateRecyclerPodSpec(&pod.Spec)
+}
 
-		case *v1.Event:
-			// Event received
-			podEvent := event.Object.(*v1.Event)
-			glog.V(4).Infof("recycler event received: %s %s/%s %s/%s %s", event.Type, podEvent.Namespace, podEvent.Name, podEvent.InvolvedObject.Namespace, podEvent.InvolvedObject.Name, podEvent.Message)
-			if event.Type == watch.Added {
-				recyclerClient.Event(podEvent.Type, podEvent.Message)
-			}
-		}
+// validateRecyclerPodSpec is the same as validateRecyclerPod, except it validates the spec of a recycler pod,
+// e.g. the pod template of a recycler job
+func validateRecyclerPodSpec(spec *v1.PodSpec) error {
+	if spec.RestartPolicy != v1.RestartPolicyNever && spec.RestartPolicy != v1.RestartPolicyOnFailure {
+		return fmt.Errorf("recycler pod restart policy must be %q or %q, got %q", v1.RestartPolicyNever, v1.RestartPolicyOnFailure, spec.RestartPolicy)
 	}
+	if len(spec.Containers) != 1 {
+		return fmt.Errorf("recycler pod must have exactly one container, got %v", len(spec.Containers))
+	}
+	return nil
 }
 
 // recyclerClient abstracts access to a Pod by providing a narrower interface.
@@ -228,6 +300,375 @@ func (c *realRecyclerClient) WatchPod(name, namespace string, stopChannel chan s
 	return eventCh, nil
 }
 
+// RecycleWorkload abstracts the workload that recycles a volume, i.e. a bare pod or a Job,
+// so that all workloads are watched by the same loop.
+type RecycleWorkload interface {
+	// Kind returns the kind of the workload for messages, e.g. "pod".
+	Kind() string
+	// Validate returns an error in case the workload would never complete.
+	Validate() error
+	// Create starts the workload, an "already exists" error means that an older instance
+	// of the workload may be already running.
+	Create() error
+	// Adopt is called in case Create returned an "already exists" error, it returns an error in case
+	// the existing workload is not an older instance of the workload, otherwise the existing workload is watched.
+	Adopt() error
+	// Watch returns a channel of watch events of the workload and of the events involving it.
+	// The stopChannel is used to stop the watch, the caller is responsible for closing it.
+	Watch(stopChannel chan struct{}) (<-chan watch.Event, error)
+	// Delete deletes the workload.
+	Delete() error
+	// Status is called with the object of each watch event of the workload, it returns (true, nil)
+	// in case the workload completed successfully, (true, error) in case it failed and (false, nil)
+	// in case it is still running.
+	Status(obj runtime.Object) (bool, error)
+	// Event sends an event to the volume that is being recycled.
+	Event(eventtype, message string)
+}
+
+// RecycleVolumeViaJob is the same as RecycleVolumeByWatchingPodUntilCompletion, except the volume
+// is recycled by the given Job instead of a bare pod, i.e. the job.Spec.BackoffLimit and
+// job.Spec.ActiveDeadlineSeconds are used to retry and to time out the recycling.
+//
+//  job - the job designed by a volume plugin to recycle the volume. In case
+//        job.Name is empty, it will be set to unique name based on PV.Name.
+//        The pod template of the job must satisfy the same requirements
+//        as the pod of RecycleVolumeByWatchingPodUntilCompletion.
+//	client - kube client for API operations.
+func RecycleVolumeViaJob(pvName string, job *batchv1.Job, kubeClient clientset.Interface, recorder RecycleEventRecorder) error {
+	_, err := internalRecycleVolumeViaJob(pvName, job, newRecyclerJobClient(kubeClient, recorder))
+	return err
+}
+
+// same as above func comments, except 'recyclerJobClient' is a narrower job API
+// interface to ease testing
+func internalRecycleVolumeViaJob(pvName string, job *batchv1.Job, recyclerJobClient recyclerJobClient) (*RecycleStats, error) {
+	if job.Name == "" {
+		job.Name = "recycler-for-" + pvName
+	}
+	job.GenerateName = ""
+	// The label ties the job to the PV, so that a foreign job with the same name is never adopted.
+	if job.Labels == nil {
+		job.Labels = make(map[string]string)
+	}
+	job.Labels[RecyclerPVLabel] = pvName
+	return recycleWorkloadWithDeadline(&jobRecycleWorkload{pvName: pvName, job: job, client: recyclerJobClient}, time.Duration(math.MaxInt64), func(elapsed, total time.Duration) {}, DefaultRecycleEventDedupWindow)
+}
+
+// recycleWorkloadWithDeadline validates and starts the workload and watches it until it completes or fails,
+// the events involving the workload are forwarded to the volume. It gives up watching the workload with an error
+// after the timeout, which must be positive, and it calls onTick periodically (recycleTicks times per the timeout)
+// with the time elapsed since the recycling started and the timeout. The events with the same type and message
+// are forwarded only once within the eventDedupWindow, zero means all events are forwarded.
+// An attempt to delete the started or adopted workload is always attempted before returning.
+// The statistics are returned even in case the recycling failed.
+func recycleWorkloadWithDeadline(workload RecycleWorkload, timeout time.Duration, onTick func(elapsed, total time.Duration), eventDedupWindow time.Duration) (*RecycleStats, error) {
+	stats := &RecycleStats{PodEvents: make(map[watch.EventType]int)}
+	if timeout <= 0 {
+		return stats, fmt.Errorf("recycler timeout must be positive, got %v", timeout)
+	}
+	if err := workload.Validate(); err != nil {
+		return stats, err
+	}
+	start := time.Now()
+
+	stopChannel := make(chan struct{})
+	defer close(stopChannel)
+	workloadCh, err := workload.Watch(stopChannel)
+	if err != nil {
+		return stats, err
+	}
+
+	// Start the workload
+	if err = workload.Create(); err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return stats, fmt.Errorf("unexpected error creating recycler %s: %+v", workload.Kind(), err)
+		}
+		if err = workload.Adopt(); err != nil {
+			return stats, err
+		}
+	}
+	defer func() {
+		if err := workload.Delete(); err != nil {
+			glog.Errorf("failed to delete recycler %s: %v", workload.Kind(), err)
+		}
+	}()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	tickInterval := timeout / recycleTicks
+	if tickInterval <= 0 {
+		tickInterval = timeout
+	}
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	deduper := newRecycleEventDeduper(eventDedupWindow)
+
+	// Now only the old workload or the new workload run. Watch it until it finishes
+	// and send all events on the workload to the PV
+	for {
+		var event watch.Event
+		var ok bool
+		select {
+		case <-ticker.C:
+			onTick(time.Since(start), timeout)
+			continue
+		case <-deadline.C:
+			return stats, fmt.Errorf("recycler %s did not complete within %v", workload.Kind(), timeout)
+		case event, ok = <-workloadCh:
+			if !ok {
+				return stats, fmt.Errorf("recycler %s watch was closed", workload.Kind())
+			}
+		}
+		if recyclerEvent, isEvent := event.Object.(*v1.Event); isEvent {
+			glog.V(4).Infof("recycler event received: %s %s/%s %s/%s %s", event.Type, recyclerEvent.Namespace, recyclerEvent.Name, recyclerEvent.InvolvedObject.Namespace, recyclerEvent.InvolvedObject.Name, recyclerEvent.Message)
+			if event.Type == watch.Added && deduper.shouldForward(recyclerEvent.Type, recyclerEvent.Message) {
+				workload.Event(recyclerEvent.Type, recyclerEvent.Message)
+			}
+			continue
+		}
+		glog.V(4).Infof("recycler %s update received: %s", workload.Kind(), event.Type)
+		stats.PodEvents[event.Type]++
+		done, err := workload.Status(event.Object)
+		switch event.Type {
+		case watch.Added, watch.Modified:
+			if done {
+				return stats, err
+			}
+		case watch.Deleted:
+			return stats, fmt.Errorf("recycler %s was deleted", workload.Kind())
+		case watch.Error:
+			return stats, fmt.Errorf("recycler %s watcher failed", workload.Kind())
+		}
+	}
+}
+
+// podRecycleWorkload is a RecycleWorkload that recycles a volume by a bare pod
+type podRecycleWorkload struct {
+	pvName string
+	pod    *v1.Pod
+	client recyclerClient
+	// phase of the recycler pod in the last received pod watch event
+	finalPhase v1.PodPhase
+}
+
+func (w *podRecycleWorkload) Kind() string {
+	return "pod"
+}
+
+func (w *podRecycleWorkload) Validate() error {
+	return validateRecyclerPod(w.pod)
+}
+
+func (w *podRecycleWorkload) Create() error {
+	_, err := w.client.CreatePod(w.pod)
+	return err
+}
+
+func (w *podRecycleWorkload) Adopt() error {
+	glog.V(5).Infof("old recycler pod %q found for volume", w.pod.Name)
+	return checkOldRecyclerPod(w.pvName, w.pod, w.client)
+}
+
+func (w *podRecycleWorkload) Watch(stopChannel chan struct{}) (<-chan watch.Event, error) {
+	podCh, err := w.client.WatchPod(w.pod.Name, w.pod.Namespace, stopChannel)
+	if err != nil {
+		glog.V(4).Infof("cannot start watcher for pod %s/%s: %v", w.pod.Namespace, w.pod.Name, err)
+	}
+	return podCh, err
+}
+
+func (w *podRecycleWorkload) Delete() error {
+	glog.V(2).Infof("deleting recycler pod %s/%s", w.pod.Namespace, w.pod.Name)
+	return w.client.DeletePod(w.pod.Name, w.pod.Namespace)
+}
+
+func (w *podRecycleWorkload) Status(obj runtime.Object) (bool, error) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return false, nil
+	}
+	glog.V(4).Infof("recycler pod update received: %s/%s %s", pod.Namespace, pod.Name, pod.Status.Phase)
+	w.finalPhase = pod.Status.Phase
+	switch pod.Status.Phase {
+	case v1.PodSucceeded:
+		// Recycle succeeded.
+		return true, nil
+	case v1.PodFailed:
+		if pod.Status.Message != "" {
+			return true, stderrors.New(pod.Status.Message)
+		}
+		return true, fmt.Errorf("pod failed, pod.Status.Message unknown.")
+	}
+	return false, nil
+}
+
+func (w *podRecycleWorkload) Event(eventtype, message string) {
+	w.client.Event(eventtype, message)
+}
+
+// jobRecycleWorkload is a RecycleWorkload that recycles a volume by a Job
+type jobRecycleWorkload struct {
+	pvName string
+	job    *batchv1.Job
+	client recyclerJobClient
+}
+
+func (w *jobRecycleWorkload) Kind() string {
+	return "job"
+}
+
+func (w *jobRecycleWorkload) Validate() error {
+	return validateRecyclerPodSpec(&w.job.Spec.Template.Spec)
+}
+
+func (w *jobRecycleWorkload) Create() error {
+	_, err := w.client.CreateJob(w.job)
+	return err
+}
+
+func (w *jobRecycleWorkload) Adopt() error {
+	glog.V(5).Infof("old recycler job %q found for volume", w.job.Name)
+	oldJob, err := w.client.GetJob(w.job.Name, w.job.Namespace)
+	if err != nil {
+		return fmt.Errorf("cannot get old recycler job %s/%s: %v", w.job.Namespace, w.job.Name, err)
+	}
+	return checkRecyclerPVLabel("job", &oldJob.ObjectMeta, w.pvName)
+}
+
+func (w *jobRecycleWorkload) Watch(stopChannel chan struct{}) (<-chan watch.Event, error) {
+	return w.client.WatchJob(w.job.Name, w.job.Namespace, stopChannel)
+}
+
+func (w *jobRecycleWorkload) Delete() error {
+	glog.V(2).Infof("deleting recycler job %s/%s", w.job.Namespace, w.job.Name)
+	return w.client.DeleteJob(w.job.Name, w.job.Namespace)
+}
+
+func (w *jobRecycleWorkload) Status(obj runtime.Object) (bool, error) {
+	job, ok := obj.(*batchv1.Job)
+	if !ok {
+		return false, nil
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != v1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return true, nil
+		case batchv1.JobFailed:
+			if condition.Message != "" {
+				return true, stderrors.New(condition.Message)
+			}
+			return true, fmt.Errorf("job failed, condition message unknown")
+		}
+	}
+	return false, nil
+}
+
+func (w *jobRecycleWorkload) Event(eventtype, message string) {
+	w.client.Event(eventtype, message)
+}
+
+// recyclerJobClient abstracts access to a Job by providing a narrower interface.
+// This makes it easier to mock a client for testing.
+type recyclerJobClient interface {
+	CreateJob(job *batchv1.Job) (*batchv1.Job, error)
+	GetJob(name, namespace string) (*batchv1.Job, error)
+	DeleteJob(name, namespace string) error
+	// WatchJob returns a channel of watch events of the job and of the events involving it.
+	// The caller is responsible for closing the stopChannel to stop the watch.
+	WatchJob(name, namespace string, stopChannel chan struct{}) (<-chan watch.Event, error)
+	// Event sends an event to the volume that is being recycled.
+	Event(eventtype, message string)
+}
+
+func newRecyclerJobClient(client clientset.Interface, recorder RecycleEventRecorder) recyclerJobClient {
+	return &realRecyclerJobClient{
+		client,
+		recorder,
+	}
+}
+
+type realRecyclerJobClient struct {
+	client   clientset.Interface
+	recorder RecycleEventRecorder
+}
+
+func (c *realRecyclerJobClient) CreateJob(job *batchv1.Job) (*batchv1.Job, error) {
+	return c.client.Batch().Jobs(job.Namespace).Create(job)
+}
+
+func (c *realRecyclerJobClient) GetJob(name, namespace string) (*batchv1.Job, error) {
+	return c.client.Batch().Jobs(namespace).Get(name, metav1.GetOptions{})
+}
+
+func (c *realRecyclerJobClient) DeleteJob(name, namespace string) error {
+	return c.client.Batch().Jobs(namespace).Delete(name, recyclerJobDeleteOptions())
+}
+
+// recyclerJobDeleteOptions returns the options of deleting a recycler job, the pods of the job are deleted in the background,
+// because the default deletion policy of batch/v1 Jobs orphans them and they would pile up after every recycling
+func recyclerJobDeleteOptions() *metav1.DeleteOptions {
+	background := metav1.DeletePropagationBackground
+	return &metav1.DeleteOptions{PropagationPolicy: &background}
+}
+
+func (c *realRecyclerJobClient) Event(eventtype, message string) {
+	c.recorder(eventtype, message)
+}
+
+func (c *realRecyclerJobClient) WatchJob(name, namespace string, stopChannel chan struct{}) (<-chan watch.Event, error) {
+	jobSelector, _ := fields.ParseSelector("metadata.name=" + name)
+	jobWatch, err := c.client.Batch().Jobs(namespace).Watch(metav1.ListOptions{
+		FieldSelector: jobSelector.String(),
+		Watch:         true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	eventSelector, _ := fields.ParseSelector("involvedObject.name=" + name)
+	eventWatch, err := c.client.Core().Events(namespace).Watch(metav1.ListOptions{
+		FieldSelector: eventSelector.String(),
+		Watch:         true,
+	})
+	if err != nil {
+		jobWatch.Stop()
+		return nil, err
+	}
+
+	eventCh := make(chan watch.Event, 0)
+
+	go func() {
+		defer eventWatch.Stop()
+		defer jobWatch.Stop()
+		defer close(eventCh)
+
+		for {
+			select {
+			case _ = <-stopChannel:
+				return
+
+			case jobEvent, ok := <-jobWatch.ResultChan():
+				if !ok {
+					return
+				}
+				eventCh <- jobEvent
+
+			case eventEvent, ok := <-eventWatch.ResultChan():
+				if !ok {
+					return
+				}
+				eventCh <- eventEvent
+			}
+		}
+	}()
+
+	return eventCh, nil
+}
+
 // CalculateTimeoutForVolume calculates time for a Recycler pod to complete a
 // recycle operation. The calculation and return value is either the
 // minimumTimeout or the timeoutIncrement per Gi of storage size, whichever is
@@ -245,6 +686,30 @@ func CalculateTimeoutForVolume(minimumTimeout, timeoutIncrement int, pv *v1.Pers
 	}
 }
 
+// RemainingRecycleTimeout returns the part of the recycle timeout calculated by CalculateTimeoutForVolume
+// that is left in case the recycling started at startedAt, e.g. for a controller that resumes the recycling
+// after a restart. Zero is returned in case the timeout was already exceeded.
+func RemainingRecycleTimeout(startedAt time.Time, pv *v1.PersistentVolume, minimumTimeout, timeoutIncrement int) time.Duration {
+	return remainingRecycleTimeout(startedAt, time.Now(), pv, minimumTimeout, timeoutIncrement)
+}
+
+// remainingRecycleTimeout is the same as RemainingRecycleTimeout, except the current time is given by now
+func remainingRecycleTimeout(startedAt, now time.Time, pv *v1.PersistentVolume, minimumTimeout, timeoutIncrement int) time.Duration {
+	timeout := time.Duration(CalculateTimeoutForVolume(minimumTimeout, timeoutIncrement, pv)) * time.Second
+	remaining := timeout - now.Sub(startedAt)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// RecyclerDeadline returns the time the recycling that started at startedAt must complete by, i.e. startedAt plus
+// the timeout calculated by CalculateTimeoutForVolume, as a Kubernetes time so that it can be written into PV status
+func RecyclerDeadline(startedAt time.Time, pv *v1.PersistentVolume, minimumTimeout, timeoutIncrement int) metav1.Time {
+	timeout := time.Duration(CalculateTimeoutForVolume(minimumTimeout, timeoutIncrement, pv)) * time.Second
+	return metav1.NewTime(startedAt.Add(timeout))
+}
+
 // RoundUpSize calculates how many allocation units are needed to accommodate
 // a volume of given size. E.g. when user wants 1500MiB volume, while AWS EBS
 // allocates volumes in gibibyte-sized chunks,
@@ -254,12 +719,79 @@ func RoundUpSize(volumeSizeBytes int64, allocationUnitBytes int64) int64 {
 	return (volumeSizeBytes + allocationUnitBytes - 1) / allocationUnitBytes
 }
 
+// RoundUpSizeWithWaste is the same as RoundUpSize, except it also returns how many bytes are wasted by rounding up,
+// e.g. RoundUpSizeWithWaste(1500 * 1024*1024, 1024*1024*1024) returns 2 and 548 * 1024*1024
+// (2 GiB are allocated to hold 1500MiB)
+func RoundUpSizeWithWaste(volumeSizeBytes, allocationUnitBytes int64) (units int64, wastedBytes int64) {
+	units = RoundUpSize(volumeSizeBytes, allocationUnitBytes)
+	return units, units*allocationUnitBytes - volumeSizeBytes
+}
+
+// CapacityRoundsEvenly returns true in case the storage capacity of the PV is an exact
+// multiple of allocationUnitBytes, i.e. RoundUpSize doesn't round the capacity up.
+// E.g. a 1500MiB PV is rounded up to 2 GiB by a provisioner that allocates volumes
+// in gibibyte-sized chunks, so false is returned for it and 1GiB allocationUnitBytes.
+func CapacityRoundsEvenly(pv *v1.PersistentVolume, allocationUnitBytes int64) bool {
+	if allocationUnitBytes <= 0 {
+		return false
+	}
+	pvQty := pv.Spec.Capacity[v1.ResourceStorage]
+	return pvQty.Value()%allocationUnitBytes == 0
+}
+
+// TotalAllocationUnits returns the sum of allocation units RoundUpSize calculates for the requested storage of each of the pvcs,
+// e.g. for a capacity planner. An error is returned in case allocationUnitBytes is not positive
+// or a PVC doesn't request storage, because the volume provisioned for it can't be estimated.
+func TotalAllocationUnits(pvcs []*v1.PersistentVolumeClaim, allocationUnitBytes int64) (int64, error) {
+	if allocationUnitBytes <= 0 {
+		return 0, fmt.Errorf("allocation unit must be positive, got %v", allocationUnitBytes)
+	}
+	var total int64
+	for _, pvc := range pvcs {
+		pvcQty, ok := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+		if !ok {
+			return 0, fmt.Errorf("PVC %s/%s does not request storage", pvc.Namespace, pvc.Name)
+		}
+		total += RoundUpSize(pvcQty.Value(), allocationUnitBytes)
+	}
+	return total, nil
+}
+
+// DefaultClusterName is the cluster name prefix of volume names used in case the cluster name is empty or contains no allowed character
+const DefaultClusterName = "kubernetes"
+
+// SanitizeClusterName converts the clusterName to a valid DNS label part, i.e. it lowercases
+// the clusterName and replaces each run of characters that are not allowed in a DNS label
+// (e.g. underscores and dots) with a single dash, e.g. "My_Cluster.01" is converted to "my-cluster-01".
+// Leading and trailing dashes are removed. DefaultClusterName is returned in case nothing is left,
+// e.g. for an empty clusterName, so that the volume name never starts with a dash.
+func SanitizeClusterName(s string) string {
+	var sanitized strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && sanitized.Len() > 0 {
+				sanitized.WriteByte('-')
+			}
+			sanitized.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	if sanitized.Len() == 0 {
+		return DefaultClusterName
+	}
+	return sanitized.String()
+}
+
 // GenerateVolumeName returns a PV name with clusterName prefix. The function
 // should be used to generate a name of GCE PD or Cinder volume. It basically
 // adds "<clusterName>-dynamic-" before the PV name, making sure the resulting
 // string fits given length and cuts "dynamic" if not.
+// The clusterName is sanitized by SanitizeClusterName first.
 func GenerateVolumeName(clusterName, pvName string, maxLength int) string {
-	prefix := clusterName + "-dynamic"
+	prefix := SanitizeClusterName(clusterName) + "-dynamic"
 	pvLen := len(pvName)
 
 	// cut the "<clusterName>-dynamic" to fit full pvName into maxLength
@@ -267,24 +799,296 @@ func GenerateVolumeName(clusterName, pvName string, maxLength int) string {
 	if pvLen+1+len(prefix) > maxLength {
 		prefix = prefix[:maxLength-pvLen-1]
 	}
-	return prefix + "-" + pvName
+	return prefix + "-" + pvName
+}
+
+// volumeNameSuffixAttempts is the number of hash suffixes GenerateVolumeNameUnique tries before it gives up
+const volumeNameSuffixAttempts = 10
+
+// GenerateVolumeNameUnique is the same as GenerateVolumeName, except the exists func is asked whether the name is already used.
+// In such case a short hash suffix derived from clusterName and pvName is appended to the name,
+// cutting the name so that it still fits given length, until a name that isn't used is found.
+// An error is returned in case no unused name is found or maxLength is too short for the suffix.
+func GenerateVolumeNameUnique(clusterName, pvName string, maxLength int, exists func(string) bool) (string, error) {
+	name := GenerateVolumeName(clusterName, pvName, maxLength)
+	if !exists(name) {
+		return name, nil
+	}
+	for attempt := 0; attempt < volumeNameSuffixAttempts; attempt++ {
+		h := fnv.New32a()
+		h.Write([]byte(clusterName + "/" + pvName + "/" + strconv.Itoa(attempt)))
+		suffix := fmt.Sprintf("-%08x", h.Sum32())
+		if maxLength <= len(suffix) {
+			return "", fmt.Errorf("volume name %q already exists and maxLength %v is too short for a unique suffix", name, maxLength)
+		}
+		candidate := name
+		if len(candidate)+len(suffix) > maxLength {
+			candidate = strings.TrimRight(candidate[:maxLength-len(suffix)], "-")
+		}
+		candidate += suffix
+		if !exists(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("failed to generate a unique volume name for PV %q in cluster %q", pvName, clusterName)
+}
+
+// Check if the path from the mounter is empty.
+func GetPath(mounter Mounter) (string, error) {
+	path := mounter.GetPath()
+	if path == "" {
+		return "", fmt.Errorf("Path is empty %s", reflect.TypeOf(mounter).String())
+	}
+	return path, nil
+}
+
+// SortedZones returns the zones sorted alphabetically, it is the order of zones the heuristics of ChooseZoneForVolume rely on
+func SortedZones(zones sets.String) []string {
+	return zones.List()
+}
+
+// FirstNZones returns the n alphabetically first zones, all zones are returned in case there are fewer than n zones
+func FirstNZones(zones sets.String, n int) []string {
+	zoneSlice := SortedZones(zones)
+	if n < 0 {
+		n = 0
+	}
+	if n < len(zoneSlice) {
+		zoneSlice = zoneSlice[:n]
+	}
+	return zoneSlice
+}
+
+// ChooseZone implements our heuristics for choosing a zone for volume creation based on the volume name
+// Volumes are generally round-robin-ed across all active zones, using the hash of the PVC Name.
+// However, if the PVCName ends with `-<integer>`, we will hash the prefix, and then add the integer to the hash.
+// This means that a StatefulSet's volumes (`claimname-statefulsetname-id`) will spread across available zones,
+// assuming the id values are consecutive.
+func ChooseZoneForVolume(zones sets.String, pvcName string) string {
+	// SortedZones returns zones in a consistent order (sorted)
+	// We do have a potential failure case where volumes will not be properly spread,
+	// if the set of zones changes during StatefulSet volume creation.  However, this is
+	// probably relatively unlikely because we expect the set of zones to be essentially
+	// static for clusters.
+	// Hopefully we can address this problem if/when we do full scheduler integration of
+	// PVC placement (which could also e.g. avoid putting volumes in overloaded or
+	// unhealthy zones)
+	zoneSlice := SortedZones(zones)
+	zone := zoneSlice[zoneIndex(pvcName, len(zoneSlice), true)]
+
+	glog.V(2).Infof("Creating volume for PVC %q; chose zone=%q from zones=%q", pvcName, zone, zoneSlice)
+	return zone
+}
+
+// ChooseZoneForVolumeNoStatefulHeuristic is the same as ChooseZoneForVolume, except the whole PVC Name
+// is always hashed, i.e. a PVCName ending with `-<integer>` is not treated as a StatefulSet volume.
+// ChooseZoneForVolume should be used for StatefulSet volumes and volumes of StatefulSet-like workloads that need
+// to be spread across zones. This func should be used when PVC Names just coincidentally end with `-<integer>`
+// (e.g. "backup-2023") and round-robin-ing them would cluster unrelated volumes in unexpected zones.
+func ChooseZoneForVolumeNoStatefulHeuristic(zones sets.String, pvcName string) string {
+	zoneSlice := SortedZones(zones)
+	zone := zoneSlice[zoneIndex(pvcName, len(zoneSlice), false)]
+
+	glog.V(2).Infof("Creating volume for PVC %q; chose zone=%q from zones=%q", pvcName, zone, zoneSlice)
+	return zone
+}
+
+// ChooseZoneForVolumeWithPin returns:
+// - pinnedZone in case it is not empty and it is one of the zones, the heuristics of ChooseZoneForVolume are bypassed
+// - error in case pinnedZone is not empty and it is not one of the zones
+// - the zone chosen by ChooseZoneForVolume in case pinnedZone is empty
+func ChooseZoneForVolumeWithPin(zones sets.String, pvcName, pinnedZone string) (string, error) {
+	if pinnedZone == "" {
+		return ChooseZoneForVolume(zones, pvcName), nil
+	}
+	if !zones.Has(pinnedZone) {
+		return "", fmt.Errorf("pinned zone %q of PVC %q is not available, available zones are %q", pinnedZone, pvcName, zones.List())
+	}
+	glog.V(2).Infof("Creating volume for PVC %q; chose pinned zone=%q", pvcName, pinnedZone)
+	return pinnedZone, nil
+}
+
+// ChooseZoneForVolumeColocated returns existingZone in case it is not empty and it is one of the zones,
+// so that another claim of a StatefulSet member ends up in the same zone as the existing volume of the member.
+// Otherwise the zone is chosen by ChooseZoneForVolume. An error is returned in case there are no zones.
+func ChooseZoneForVolumeColocated(zones sets.String, pvcName, existingZone string) (string, error) {
+	if len(zones) == 0 {
+		return "", fmt.Errorf("no zone available for PVC %q", pvcName)
+	}
+	if existingZone != "" && zones.Has(existingZone) {
+		glog.V(2).Infof("Creating volume for PVC %q; chose zone=%q of the existing volume", pvcName, existingZone)
+		return existingZone, nil
+	}
+	return ChooseZoneForVolume(zones, pvcName), nil
+}
+
+// ChooseZoneForVolumeHealthy is the same as ChooseZoneForVolume, except the unhealthy zones are subtracted from the zones first,
+// an error is returned in case no healthy zone is left
+func ChooseZoneForVolumeHealthy(zones, unhealthy sets.String, pvcName string) (string, error) {
+	healthy := zones.Difference(unhealthy)
+	if len(healthy) == 0 {
+		return "", fmt.Errorf("no healthy zone available for PVC %q, zones are %q and unhealthy zones are %q", pvcName, zones.List(), unhealthy.List())
+	}
+	return ChooseZoneForVolume(healthy, pvcName), nil
+}
+
+// ChooseZoneForVolumeStable is the same as ChooseZoneForVolume, except the hash of the PVC Name is taken modulo knownZoneCount
+// instead of the number of zones, and the zone is chosen from the alphabetically first knownZoneCount zones.
+// So the new members of a scaled up StatefulSet continue the round-robin of the existing members
+// and no existing index is remapped in case a zone that sorts after the known zones becomes available later.
+// An error is returned in case knownZoneCount is not positive or it is greater than the number of zones.
+func ChooseZoneForVolumeStable(zones sets.String, pvcName string, knownZoneCount int) (string, error) {
+	if knownZoneCount < 1 || knownZoneCount > len(zones) {
+		return "", fmt.Errorf("known zone count of PVC %q must be between 1 and the number of zones %v, got %v", pvcName, len(zones), knownZoneCount)
+	}
+	zoneSlice := FirstNZones(zones, knownZoneCount)
+	zone := zoneSlice[zoneIndex(pvcName, knownZoneCount, true)]
+
+	glog.V(2).Infof("Creating volume for PVC %q; chose zone=%q from known zones=%q", pvcName, zone, zoneSlice)
+	return zone, nil
+}
+
+// WouldShareZone returns true in case ChooseZoneForVolume chooses the same zone for both PVC Names,
+// e.g. to verify that volumes of a StatefulSet member are co-located. The zones must not be empty.
+func WouldShareZone(zones sets.String, pvcNameA, pvcNameB string) bool {
+	return ChooseZoneForVolume(zones, pvcNameA) == ChooseZoneForVolume(zones, pvcNameB)
+}
+
+// ChooseZoneForVolumeWithPriority returns the first zone of priority that is one of the zones, e.g. a zone ordering preferred by the cloud provider.
+// The priority overrides spreading volumes across zones, i.e. all volumes end up in the same zone while it is available.
+// The zone is chosen by ChooseZoneForVolume in case none of the priority zones is available.
+func ChooseZoneForVolumeWithPriority(zones sets.String, pvcName string, priority []string) string {
+	for _, zone := range priority {
+		if zones.Has(zone) {
+			glog.V(2).Infof("Creating volume for PVC %q; chose zone=%q by priority=%q", pvcName, zone, priority)
+			return zone
+		}
+	}
+	return ChooseZoneForVolume(zones, pvcName)
+}
+
+// ChooseTwoZonesSameRegion chooses two distinct zones of a single region for a regional volume (e.g. GCE Regional PD).
+// Only the regions with at least two zones are considered, the region and the first zone are chosen
+// by the heuristics of ChooseZoneForVolume, the second zone is the next one in the alphabetical order of zones of the region.
+// An error is returned in case no region has at least two zones.
+func ChooseTwoZonesSameRegion(zonesByRegion map[string]sets.String, pvcName string) (sets.String, error) {
+	regions := sets.NewString()
+	for region, zones := range zonesByRegion {
+		if len(zones) >= 2 {
+			regions.Insert(region)
+		}
+	}
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("no region with at least two zones is available for regional PVC %q", pvcName)
+	}
+	regionSlice := regions.List()
+	region := regionSlice[zoneIndex(pvcName, len(regionSlice), true)]
+	zoneSlice := SortedZones(zonesByRegion[region])
+	first := zoneIndex(pvcName, len(zoneSlice), true)
+	second := (first + 1) % len(zoneSlice)
+
+	glog.V(2).Infof("Creating regional volume for PVC %q; chose zones=%q and %q of region=%q", pvcName, zoneSlice[first], zoneSlice[second], region)
+	return sets.NewString(zoneSlice[first], zoneSlice[second]), nil
+}
+
+// ZoneChooser chooses zones for volumes the same way as ChooseZoneForVolume,
+// but the zones are sorted only once when the ZoneChooser is created, so it
+// should be used to choose zones for many volumes from a static set of zones.
+type ZoneChooser struct {
+	// sorted zones
+	zoneSlice []string
+}
+
+// NewZoneChooser returns a ZoneChooser for the zones, the zones must not be empty
+func NewZoneChooser(zones sets.String) *ZoneChooser {
+	return &ZoneChooser{zoneSlice: SortedZones(zones)}
+}
+
+// Choose returns the same zone as ChooseZoneForVolume for the zones of the ZoneChooser
+func (c *ZoneChooser) Choose(pvcName string) string {
+	zone := c.zoneSlice[zoneIndex(pvcName, len(c.zoneSlice), true)]
+
+	glog.V(2).Infof("Creating volume for PVC %q; chose zone=%q from zones=%q", pvcName, zone, c.zoneSlice)
+	return zone
+}
+
+// ZoneBalancer chooses the zone with the fewest volumes placed by the ZoneBalancer so far, so that volumes are
+// spread evenly across zones over time. Unlike ChooseZoneForVolume it doesn't depend on the PVC name, so it
+// should be used by provisioners that don't need to co-locate the volumes of a StatefulSet member.
+type ZoneBalancer struct {
+	// number of volumes placed in each zone
+	placed map[string]int
+}
+
+// NewZoneBalancer returns a ZoneBalancer that hasn't placed any volume yet
+func NewZoneBalancer() *ZoneBalancer {
+	return &ZoneBalancer{placed: make(map[string]int)}
+}
+
+// Next returns the zone with the fewest volumes placed so far, the alphabetically first zone wins a tie.
+// An empty string is returned in case the zones are empty. Record must be called once a volume is placed in the zone.
+func (b *ZoneBalancer) Next(zones sets.String) string {
+	next := ""
+	for _, zone := range SortedZones(zones) {
+		if next == "" || b.placed[zone] < b.placed[next] {
+			next = zone
+		}
+	}
+	return next
+}
+
+// Record records that a volume was placed in the zone
+func (b *ZoneBalancer) Record(zone string) {
+	b.placed[zone]++
+}
+
+// ZonePreferenceOrder returns all zones ordered by preference for volume creation.
+// The first zone is the one ChooseZoneForVolume chooses, the rest of zones follow
+// in the sorted order, wrapping around at the end, so that a provisioner has
+// a deterministic list of zones to retry in case the volume creation fails.
+func ZonePreferenceOrder(zones sets.String, pvcName string) []string {
+	zoneSlice := SortedZones(zones)
+	if len(zoneSlice) == 0 {
+		return zoneSlice
+	}
+	first := zoneIndex(pvcName, len(zoneSlice), true)
+	return append(zoneSlice[first:], zoneSlice[:first]...)
+}
+
+// ZoneDistribution returns how many of the pvcNames ChooseZoneForVolume places in each of the zones,
+// so that the spreading of volumes across zones can be checked
+func ZoneDistribution(zones sets.String, pvcNames []string) map[string]int {
+	distribution := make(map[string]int)
+	zoneSlice := SortedZones(zones)
+	if len(zoneSlice) == 0 {
+		return distribution
+	}
+	for _, zone := range zoneSlice {
+		distribution[zone] = 0
+	}
+	for _, pvcName := range pvcNames {
+		distribution[zoneSlice[zoneIndex(pvcName, len(zoneSlice), true)]]++
+	}
+	return distribution
 }
 
-// Check if the path from the mounter is empty.
-func GetPath(mounter Mounter) (string, error) {
-	path := mounter.GetPath()
-	if path == "" {
-		return "", fmt.Errorf("Path is empty %s", reflect.TypeOf(mounter).String())
+// ZoneAssignments returns the zone ChooseZoneForVolume chooses for each of the pvcNames, so that the assignments
+// can be snapshotted and any change of the heuristics that would move existing volumes to another zone is caught
+func ZoneAssignments(zones sets.String, pvcNames []string) map[string]string {
+	assignments := make(map[string]string)
+	if len(zones) == 0 {
+		return assignments
 	}
-	return path, nil
+	for _, pvcName := range pvcNames {
+		assignments[pvcName] = ChooseZoneForVolume(zones, pvcName)
+	}
+	return assignments
 }
 
-// ChooseZone implements our heuristics for choosing a zone for volume creation based on the volume name
-// Volumes are generally round-robin-ed across all active zones, using the hash of the PVC Name.
-// However, if the PVCName ends with `-<integer>`, we will hash the prefix, and then add the integer to the hash.
-// This means that a StatefulSet's volumes (`claimname-statefulsetname-id`) will spread across available zones,
-// assuming the id values are consecutive.
-func ChooseZoneForVolume(zones sets.String, pvcName string) string {
+// zoneIndex implements the heuristics of ChooseZoneForVolume, it returns the index
+// of the chosen zone in the sorted list of zoneCount zones.
+// The StatefulSet heuristic is skipped and the whole pvcName is hashed in case statefulSetHeuristic is false.
+func zoneIndex(pvcName string, zoneCount int, statefulSetHeuristic bool) int {
 	// We create the volume in a zone determined by the name
 	// Eventually the scheduler will coordinate placement into an available zone
 	var hash uint32
@@ -307,7 +1111,10 @@ func ChooseZoneForVolume(zones sets.String, pvcName string) string {
 		// it looks like `ClaimName-StatefulSetName-Id`.
 		// We continue to round-robin volume names that look like `Name-Id` also; this is a useful
 		// feature for users that are creating statefulset-like functionality without using statefulsets.
-		lastDash := strings.LastIndexByte(pvcName, '-')
+		lastDash := -1
+		if statefulSetHeuristic {
+			lastDash = strings.LastIndexByte(pvcName, '-')
+		}
 		if lastDash != -1 {
 			statefulsetIDString := pvcName[lastDash+1:]
 			statefulsetID, err := strconv.ParseUint(statefulsetIDString, 10, 32)
@@ -338,19 +1145,33 @@ func ChooseZoneForVolume(zones sets.String, pvcName string) string {
 		hash = h.Sum32()
 	}
 
-	// Zones.List returns zones in a consistent order (sorted)
-	// We do have a potential failure case where volumes will not be properly spread,
-	// if the set of zones changes during StatefulSet volume creation.  However, this is
-	// probably relatively unlikely because we expect the set of zones to be essentially
-	// static for clusters.
-	// Hopefully we can address this problem if/when we do full scheduler integration of
-	// PVC placement (which could also e.g. avoid putting volumes in overloaded or
-	// unhealthy zones)
-	zoneSlice := zones.List()
-	zone := zoneSlice[(hash+index)%uint32(len(zoneSlice))]
+	return int((hash + index) % uint32(zoneCount))
+}
 
-	glog.V(2).Infof("Creating volume for PVC %q; chose zone=%q from zones=%q", pvcName, zone, zoneSlice)
-	return zone
+// StatefulSetHashKey splits a StatefulSet-style PVC Name `ClaimName-StatefulSetName-Id` into the key that
+// identifies the StatefulSet member's claims and the Id, isStatefulSet is false and the whole pvcName is the key
+// in case the pvcName doesn't end with `-<integer>`.
+// Both the ClaimName and the StatefulSetName may contain dashes, so the boundary between them can't be found
+// from a single pvcName. The only part every claim of a member shares is the portion after the final dash
+// of `ClaimName-StatefulSetName`, so it is the key, e.g. "web" for both "my-data-web-0" and "logs-web-0".
+// It is the same portion ChooseZoneForVolume hashes, so the claims of a member end up in the same zone.
+// A StatefulSet with a dashed name shares the key with other StatefulSets whose names end the same way,
+// e.g. "data-my-app-0" and "data-your-app-0" have the key "app", which only makes the spreading suboptimal.
+// A `Name-Id` pvcName has the key Name.
+func StatefulSetHashKey(pvcName string) (key string, index uint32, isStatefulSet bool) {
+	lastDash := strings.LastIndexByte(pvcName, '-')
+	if lastDash == -1 {
+		return pvcName, 0, false
+	}
+	statefulsetID, err := strconv.ParseUint(pvcName[lastDash+1:], 10, 32)
+	if err != nil {
+		return pvcName, 0, false
+	}
+	key = pvcName[:lastDash]
+	if keyDash := strings.LastIndexByte(key, '-'); keyDash != -1 {
+		key = key[keyDash+1:]
+	}
+	return key, uint32(statefulsetID), true
 }
 
 // UnmountViaEmptyDir delegates the tear down operation for secret, configmap, git_repo and downwardapi
@@ -373,20 +1194,76 @@ func UnmountViaEmptyDir(dir string, host VolumeHost, volName string, volSpec Spe
 	return wrapped.TearDownAt(dir)
 }
 
-// zonesToSet converts a string containing a comma separated list of zones to set
+// zonesToSet converts a string containing a list of zones to set.
+// Zones are separated by commas, semicolons or whitespace (including newlines) and runs of whitespace are collapsed,
+// e.g. "a, b\nc;d" is converted to the set of zones a, b, c and d.
+// An empty zone between commas or semicolons is an error.
 func zonesToSet(zonesString string) (sets.String, error) {
-	zonesSlice := strings.Split(zonesString, ",")
+	zonesSlice := strings.Split(strings.Replace(zonesString, ";", ",", -1), ",")
 	zonesSet := make(sets.String)
 	for _, zone := range zonesSlice {
-		trimmedZone := strings.TrimSpace(zone)
-		if trimmedZone == "" {
-			return make(sets.String), fmt.Errorf("comma separated list of zones (%q) must not contain an empty zone", zonesString)
+		splitZones := strings.Fields(zone)
+		if len(splitZones) == 0 {
+			return make(sets.String), fmt.Errorf("list of zones (%q) must not contain an empty zone", zonesString)
 		}
-		zonesSet.Insert(trimmedZone)
+		zonesSet.Insert(splitZones...)
 	}
 	return zonesSet, nil
 }
 
+// ZonesToNodeAffinity converts a set of zones, e.g. the result of ResolveZones, to a node affinity of a volume,
+// so the volume is only used on nodes in one of the zones. The zones are listed in sorted order.
+func ZonesToNodeAffinity(zones sets.String) *v1.VolumeNodeAffinity {
+	return &v1.VolumeNodeAffinity{
+		Required: &v1.NodeSelector{
+			NodeSelectorTerms: []v1.NodeSelectorTerm{
+				{
+					MatchExpressions: []v1.NodeSelectorRequirement{
+						{
+							Key:      metav1.LabelZoneFailureDomain,
+							Operator: v1.NodeSelectorOpIn,
+							Values:   zones.List(),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// NodeAffinityToZones converts a node affinity of a volume back to the set of zones the volume is pinned to and returns:
+// - the set of zones, node selector terms are ORed so their zones are united, while zone requirements of a single term are intersected
+// - error in case the node affinity is missing or a term doesn't contain a zone requirement
+// - error in case a zone requirement uses another operator than In
+// Requirements on other keys than the zone label are ignored.
+func NodeAffinityToZones(aff *v1.VolumeNodeAffinity) (sets.String, error) {
+	if aff == nil || aff.Required == nil || len(aff.Required.NodeSelectorTerms) == 0 {
+		return nil, fmt.Errorf("volume node affinity is missing")
+	}
+	ret := make(sets.String)
+	for _, term := range aff.Required.NodeSelectorTerms {
+		var termZones sets.String
+		for _, req := range term.MatchExpressions {
+			if req.Key != metav1.LabelZoneFailureDomain {
+				continue
+			}
+			if req.Operator != v1.NodeSelectorOpIn {
+				return nil, fmt.Errorf("operator %q of the %q requirement is not supported, only %q is supported", req.Operator, req.Key, v1.NodeSelectorOpIn)
+			}
+			if termZones == nil {
+				termZones = sets.NewString(req.Values...)
+			} else {
+				termZones = termZones.Intersection(sets.NewString(req.Values...))
+			}
+		}
+		if termZones == nil {
+			return nil, fmt.Errorf("node selector term (%v) does not contain a %q requirement", term, metav1.LabelZoneFailureDomain)
+		}
+		ret = ret.Union(termZones)
+	}
+	return ret, nil
+}
+
 // validatePVCSelector validates Selector part of a PVC:
 // - in case there is no Selector the PVC is valid
 // - makes sure that only allowedKeys are present in the Selector matchLabels part
@@ -417,7 +1294,7 @@ func validatePVCSelector(pvc *v1.PersistentVolumeClaim) (bool, error) {
 			return false, fmt.Errorf("key %q is not permitted in selector.matchExpressions", expr.Key)
 		}
 		if !allowedOperators[expr.Operator] {
-			return false, fmt.Errorf("operator %q is not permitted in selector.matchExpressions", expr.Operator)
+			return false, operatorNotPermittedError(expr.Key, expr.Operator)
 		}
 		if len(expr.Values) < 1 {
 			return false, fmt.Errorf("key %q, operator %q pair does not contain any value(s) in selector.matchExpressions", expr.Key, expr.Operator)
@@ -426,6 +1303,18 @@ func validatePVCSelector(pvc *v1.PersistentVolumeClaim) (bool, error) {
 	return false, nil
 }
 
+// operatorNotPermittedError returns an error for an operator that is not permitted in selector.matchExpressions,
+// the error guides the user towards the In and NotIn operators in case the operator is known, but makes no sense for zones and regions
+func operatorNotPermittedError(key string, operator metav1.LabelSelectorOperator) error {
+	switch operator {
+	case "Gt", "Lt":
+		return fmt.Errorf("operator %q is not permitted in selector.matchExpressions, values of key %q are names that can't be compared as numbers, use operator %q or %q to list the allowed or forbidden values", operator, key, metav1.LabelSelectorOpIn, metav1.LabelSelectorOpNotIn)
+	case metav1.LabelSelectorOpExists, metav1.LabelSelectorOpDoesNotExist:
+		return fmt.Errorf("operator %q is not permitted in selector.matchExpressions, every volume has key %q, use operator %q or %q to list the allowed or forbidden values", operator, key, metav1.LabelSelectorOpIn, metav1.LabelSelectorOpNotIn)
+	}
+	return fmt.Errorf("operator %q is not permitted in selector.matchExpressions", operator)
+}
+
 // getPVCMatchLabel returns:
 // - either (value, nil) for the key from the matchLabels Selector part of the PVC
 // - or ("", error) in case the key is missing in the matchLabels Selector part of the PVC
@@ -488,18 +1377,40 @@ func getPVCMatchExpression(pvc *v1.PersistentVolumeClaim, key string, operator m
 	return ret, nil
 }
 
+// ErrNoSatisfyingZone is returned (wrapped) by GetConfZones and ResolveZones in case no available zone satisfies
+// both admin configured zones and user configured regions and zones
+var ErrNoSatisfyingZone = stderrors.New("Could not find availability zone")
+
+// ErrZoneAndZones is returned in case both zone and zones StorageClass parameters are configured
+var ErrZoneAndZones = stderrors.New("both zone and zones StorageClass parameters must not be used at the same time")
+
 // ZonesConf is a class for calculation of a set of zones that satisfy both admin configured zones and user configured regions and zones
 type ZonesConf struct {
 	// PVC data structure containing the user configured regions and zones
 	PVC *v1.PersistentVolumeClaim
 	// a func that returns a set of all available zones
 	GetAllZones func() (sets.String, error)
+	// an optional func that returns a set of all available zones and can be cancelled via the context,
+	// it is used instead of the func GetAllZones in case it is set
+	GetAllZonesCtx func(ctx context.Context) (sets.String, error)
 	// a func that converts a zone to a region
 	ZoneToRegion func(string) (string, error)
+	// the zone used in case neither the StorageClass parameters nor the selector part of the PVC narrow the set of zones,
+	// all available zones are used in such case if the DefaultZone is empty
+	DefaultZone string
+	// in case it is true and the resulting zones span several regions, ResolveZones returns only the zones of the region
+	// with the most resulting zones, ties are broken by the region name; it is a heuristic that avoids cross-region volumes
+	// most workloads don't expect, the func ZoneToRegion must be set
+	PreferSingleRegion bool
+	// zones excluded from provisioning, e.g. during a zone maintenance, ResolveZones subtracts them after the StorageClass parameters
+	// and the selector are applied, i.e. an excluded zone is never returned even in case the selector asks for it
+	ExcludedZones sets.String
 	// is the parameter zone specified in the Storage Class by an admin?
 	isSCZoneConfigured bool
 	// is the parameter zones specified in the Storage Class by an admin?
 	isSCZonesConfigured bool
+	// the zone(s) specified in the Storage Class by an admin, the resultingZones start from them
+	scZones sets.String
 	// true if the func GetAllZones was already called
 	gotAllAvailableZones bool
 	// contains the return value of the func GetAllZones call
@@ -510,6 +1421,34 @@ type ZonesConf struct {
 	isRegionToZonesMapValid bool
 	// maps a single region to a set of all zones that are available in the region
 	regionToZonesMap map[string]sets.String
+	// descriptions of the selector constraints that narrowed the resultingZones in the last ResolveZones call
+	appliedConstraints []string
+}
+
+// ForPVC returns a ZonesConf for another PVC with the same configuration as z, e.g. to provision many PVCs
+// of the same StorageClass. The set of all available zones and the region map already calculated by z
+// are reused, so the funcs GetAllZones and ZoneToRegion are not called again for them.
+func (z *ZonesConf) ForPVC(pvc *v1.PersistentVolumeClaim) *ZonesConf {
+	c := &ZonesConf{
+		PVC:                     pvc,
+		GetAllZones:             z.GetAllZones,
+		GetAllZonesCtx:          z.GetAllZonesCtx,
+		ZoneToRegion:            z.ZoneToRegion,
+		DefaultZone:             z.DefaultZone,
+		PreferSingleRegion:      z.PreferSingleRegion,
+		ExcludedZones:           z.ExcludedZones,
+		isSCZoneConfigured:      z.isSCZoneConfigured,
+		isSCZonesConfigured:     z.isSCZonesConfigured,
+		scZones:                 z.scZones,
+		gotAllAvailableZones:    z.gotAllAvailableZones,
+		allAvailableZones:       z.allAvailableZones,
+		isRegionToZonesMapValid: z.isRegionToZonesMapValid,
+		regionToZonesMap:        z.regionToZonesMap,
+	}
+	if c.isSCZoneConfigured || c.isSCZonesConfigured {
+		c.resultingZones = sets.NewString(z.scZones.List()...)
+	}
+	return c
 }
 
 // SetZone sets the zone StorageClass parameter configured by an admin and returns:
@@ -517,10 +1456,10 @@ type ZonesConf struct {
 // - nil the zone StorageClass parameter was successfully set
 func (z *ZonesConf) SetZone(zone string) error {
 	if z.isSCZonesConfigured {
-		return fmt.Errorf("both zone and zones StorageClass parameters must not be used at the same time")
+		return ErrZoneAndZones
 	}
-	z.resultingZones = make(sets.String)
-	z.resultingZones.Insert(zone)
+	z.scZones = sets.NewString(zone)
+	z.resultingZones = sets.NewString(zone)
 	z.isSCZoneConfigured = true
 	return nil
 }
@@ -531,32 +1470,173 @@ func (z *ZonesConf) SetZone(zone string) error {
 // - nil the zones StorageClass parameter was successfully parsed and set
 func (z *ZonesConf) SetZones(zones string) error {
 	if z.isSCZoneConfigured {
-		return fmt.Errorf("both zone and zones StorageClass parameters must not be used at the same time")
+		return ErrZoneAndZones
 	}
 	var err error
-	if z.resultingZones, err = zonesToSet(zones); err != nil {
+	if z.scZones, err = zonesToSet(zones); err != nil {
 		return fmt.Errorf("corresponding storage class error: %v", err.Error())
 	}
+	z.resultingZones = sets.NewString(z.scZones.List()...)
 	z.isSCZonesConfigured = true
 	return nil
 }
 
+// zoneFormats maps a cloud provider to the format of its zone names
+var zoneFormats = map[string]*regexp.Regexp{
+	// region followed by a letter, e.g. "us-east-1a"
+	"aws": regexp.MustCompile(`^[a-z]{2}(-gov)?-[a-z]+-[0-9]+[a-z]$`),
+	// region, a dash and a letter, e.g. "us-central1-a"
+	"gce": regexp.MustCompile(`^[a-z]+-[a-z]+[0-9]+-[a-z]$`),
+	// a number, e.g. "1"
+	"azure": regexp.MustCompile(`^[0-9]+$`),
+}
+
+// ValidateZoneFormat returns an error in case the zone doesn't match the format of zone names of the provider
+// ("aws", "gce" or "azure"), so that a typo in a zone is caught before a volume is provisioned,
+// an error is returned for an unknown provider too
+func ValidateZoneFormat(zone string, provider string) error {
+	format, ok := zoneFormats[provider]
+	if !ok {
+		return fmt.Errorf("unknown provider %q, zone format is known for providers %q", provider, sets.StringKeySet(zoneFormats).List())
+	}
+	if !format.MatchString(zone) {
+		return fmt.Errorf("zone %q is not a valid %s zone, it must match %q", zone, provider, format.String())
+	}
+	return nil
+}
+
+// ValidateStorageClassZoneParams validates the zone and zones StorageClass parameters configured by an admin and returns:
+// - error in case both zone and zones StorageClass parameters are configured
+// - error in case the zone StorageClass parameter contains a delimiter of zonesToSet, i.e. a comma, a semicolon or whitespace,
+//   so it was probably meant to be the zones parameter
+// - error in case the zones StorageClass parameter does not contain a comma separated list of zones
+// - nil in case the parameters are valid, an empty string means the parameter is not configured
+func ValidateStorageClassZoneParams(zone, zones string) error {
+	if zone != "" && zones != "" {
+		return ErrZoneAndZones
+	}
+	if strings.ContainsAny(zone, ",;") || strings.IndexFunc(zone, unicode.IsSpace) != -1 {
+		return fmt.Errorf("zone StorageClass parameter (%q) must contain a single zone, use the zones parameter for a list of zones", zone)
+	}
+	if zones != "" {
+		if _, err := zonesToSet(zones); err != nil {
+			return fmt.Errorf("corresponding storage class error: %v", err.Error())
+		}
+	}
+	return nil
+}
+
+// ValidateZoneConfig validates the zone and zones StorageClass parameters configured by an admin and the selector part of the PVC in a single call,
+// e.g. for admission, and returns:
+// - error returned by ValidateStorageClassZoneParams in case the StorageClass parameters are not valid
+// - error in case the PVC is missing or its selector is not valid
+// - nil in case both the StorageClass parameters and the PVC are valid
+func ValidateZoneConfig(scZone, scZones string, pvc *v1.PersistentVolumeClaim) error {
+	if err := ValidateStorageClassZoneParams(scZone, scZones); err != nil {
+		return err
+	}
+	if pvc == nil {
+		return fmt.Errorf("PVC must be set to validate its selector")
+	}
+	if _, err := validatePVCSelector(pvc); err != nil {
+		return fmt.Errorf("selector of PVC %q is not valid: %v", pvc.Name, err)
+	}
+	return nil
+}
+
+// Validate returns:
+// - error in case the PVC or both the func GetAllZones and GetAllZonesCtx are missing
+// - error in case the func ZoneToRegion is missing, while the selector part of the PVC contains a region or PreferSingleRegion is set
+// - nil in case everything ResolveZones needs is present
+func (z *ZonesConf) Validate() error {
+	if z.PVC == nil {
+		return fmt.Errorf("PVC must be set in ZonesConf")
+	}
+	if z.GetAllZones == nil && z.GetAllZonesCtx == nil {
+		return fmt.Errorf("func GetAllZones or GetAllZonesCtx must be set in ZonesConf")
+	}
+	if z.ZoneToRegion == nil && SelectorUsesRegions(z.PVC) {
+		return fmt.Errorf("selector of PVC %q uses region labels but no ZoneToRegion mapping was provided in ZonesConf", z.PVC.Name)
+	}
+	if z.ZoneToRegion == nil && z.PreferSingleRegion {
+		return fmt.Errorf("func ZoneToRegion must be set in ZonesConf, because PreferSingleRegion is set")
+	}
+	return nil
+}
+
+// SelectorUsesRegions returns true in case the matchLabels or the matchExpressions Selector part of the PVC contains the region key.
+// Only for such PVC the regions of all available zones have to be found out via the func ZoneToRegion.
+func SelectorUsesRegions(pvc *v1.PersistentVolumeClaim) bool {
+	if pvc == nil || pvc.Spec.Selector == nil {
+		return false
+	}
+	if _, ok := pvc.Spec.Selector.MatchLabels[metav1.LabelZoneRegion]; ok {
+		return true
+	}
+	for _, expr := range pvc.Spec.Selector.MatchExpressions {
+		if expr.Key == metav1.LabelZoneRegion {
+			return true
+		}
+	}
+	return false
+}
+
 // getAllAvailableZones caches the result of the func GetAllZones call so it returns:
 // - cached result stored in z.allAvailableZones
 // - error in case the func GetAllZones returned and error
 // - the return value of the func GetAllZones call
 func (z *ZonesConf) getAllAvailableZones() (sets.String, error) {
+	return z.getAllAvailableZonesContext(context.Background())
+}
+
+// getAllAvailableZonesContext is the same as getAllAvailableZones, except the func GetAllZonesCtx is called
+// with the ctx in case it is set and it returns ctx.Err() in case the ctx is cancelled before the call finishes
+func (z *ZonesConf) getAllAvailableZonesContext(ctx context.Context) (sets.String, error) {
 	if z.gotAllAvailableZones {
 		return z.allAvailableZones, nil
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	var err error
-	if z.allAvailableZones, err = z.GetAllZones(); err != nil {
+	if z.GetAllZonesCtx != nil {
+		z.allAvailableZones, err = z.GetAllZonesCtx(ctx)
+	} else if ctx.Done() == nil {
+		// the ctx can never be cancelled, so there is no need to wait for the func GetAllZones in a goroutine
+		z.allAvailableZones, err = z.GetAllZones()
+	} else {
+		z.allAvailableZones, err = getAllZonesContext(ctx, z.GetAllZones)
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil {
 		return nil, err
 	}
 	z.gotAllAvailableZones = true
 	return z.allAvailableZones, nil
 }
 
+// getAllZonesContext calls the getAllZones in a goroutine, so that ctx.Err() is returned as soon as the ctx is cancelled,
+// the func getAllZones itself can't be cancelled, so its result is dropped in such case
+func getAllZonesContext(ctx context.Context, getAllZones func() (sets.String, error)) (sets.String, error) {
+	type result struct {
+		zones sets.String
+		err   error
+	}
+	results := make(chan result, 1)
+	go func() {
+		zones, err := getAllZones()
+		results <- result{zones, err}
+	}()
+	select {
+	case r := <-results:
+		return r.zones, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 // regionToZones converts a single region into a set of zones
 func (z *ZonesConf) regionToZones(region string) (sets.String, error) {
 	if !z.isRegionToZonesMapValid {
@@ -567,8 +1647,20 @@ func (z *ZonesConf) regionToZones(region string) (sets.String, error) {
 	return z.regionToZonesMap[region], nil
 }
 
+// ZonesInRegion returns:
+// - a set of all available zones in the region, the set is empty in case the region is unknown
+// - error in case the func ZoneToRegion is missing or the func GetAllZones or func ZoneToRegion failed
+func (z *ZonesConf) ZonesInRegion(region string) (sets.String, error) {
+	zones, err := z.regionToZones(region)
+	if err != nil {
+		return nil, err
+	}
+	return sets.NewString(zones.List()...), nil
+}
+
 // calculateRegionToZonesMap returns:
 // - nil if the z.regionToZonesMap was successfully calculated
+// - error if the func ZoneToRegion is missing
 // - error if the func GetAllZones or func ZoneToRegion failed
 // Currently cloud providers do not provide a func RegionToZone that will return all zones that are available in a given region.
 // Thats why the func calculateRegionToZonesMap goes through allAvailableZones and creates a map region -> set of zones that are available in the region.
@@ -576,6 +1668,9 @@ func (z *ZonesConf) calculateRegionToZonesMap() error {
 	if z.isRegionToZonesMapValid {
 		return nil
 	}
+	if z.ZoneToRegion == nil {
+		return fmt.Errorf("no ZoneToRegion mapping was provided in ZonesConf to convert zones to regions")
+	}
 	z.regionToZonesMap = make(map[string]sets.String)
 	var err error
 	if !z.gotAllAvailableZones {
@@ -597,6 +1692,102 @@ func (z *ZonesConf) calculateRegionToZonesMap() error {
 	return nil
 }
 
+// defaultZone narrows z.resultingZones to the DefaultZone and returns:
+// - a set containing only the DefaultZone
+// - error in case the DefaultZone is not available
+func (z *ZonesConf) defaultZone() (sets.String, error) {
+	if !z.resultingZones.Has(z.DefaultZone) {
+		return nil, fmt.Errorf("default zone %q is not available, available zones are %q", z.DefaultZone, z.resultingZones.List())
+	}
+	z.resultingZones = sets.NewString(z.DefaultZone)
+	return z.resultingZones, nil
+}
+
+// finalZones subtracts the ExcludedZones from z.resultingZones and narrows them to a single region in case PreferSingleRegion is set, it returns:
+// - the final set of zones
+// - error in case no zone is left after the ExcludedZones are subtracted or the func ZoneToRegion failed
+func (z *ZonesConf) finalZones() (sets.String, error) {
+	if len(z.ExcludedZones) > 0 {
+		z.resultingZones = z.resultingZones.Difference(z.ExcludedZones)
+		if len(z.resultingZones) == 0 {
+			return nil, noSatisfyingZone("excluded zones")
+		}
+	}
+	return z.singleRegionZones()
+}
+
+// singleRegionZones narrows z.resultingZones to the zones of a single region in case PreferSingleRegion is set and returns:
+// - z.resultingZones narrowed to the region with the most zones, the alphabetically first region wins a tie
+// - error in case the func ZoneToRegion failed
+func (z *ZonesConf) singleRegionZones() (sets.String, error) {
+	if !z.PreferSingleRegion {
+		return z.resultingZones, nil
+	}
+	regionZones := make(map[string]sets.String)
+	for zone := range z.resultingZones {
+		region, err := z.ZoneToRegion(zone)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert zone (%v) to a region: %v", zone, err)
+		}
+		if _, ok := regionZones[region]; !ok {
+			regionZones[region] = make(sets.String)
+		}
+		regionZones[region].Insert(zone)
+	}
+	bestRegion := ""
+	for region, zones := range regionZones {
+		if bestRegion == "" || len(zones) > len(regionZones[bestRegion]) || (len(zones) == len(regionZones[bestRegion]) && region < bestRegion) {
+			bestRegion = region
+		}
+	}
+	if len(regionZones) > 1 {
+		glog.V(4).Infof("zones %q of PVC %q span several regions, preferring region %q", z.resultingZones.List(), z.PVC.Name, bestRegion)
+		z.resultingZones = regionZones[bestRegion]
+	}
+	return z.resultingZones, nil
+}
+
+// noSatisfyingZone returns ErrNoSatisfyingZone with a note about which part of the selector emptied the set of zones
+func noSatisfyingZone(stage string) error {
+	return fmt.Errorf("%w: combination of StorageClass parameters and selector of this claim cannot be satisfied by this cluster (no zone left after %s)", ErrNoSatisfyingZone, stage)
+}
+
+// GetConfZonesContext is the same as ResolveZones, except the set of all available zones is always
+// requested first and the request can be cancelled via the ctx, in which case ctx.Err() is returned
+func (z *ZonesConf) GetConfZonesContext(ctx context.Context) (sets.String, error) {
+	if err := z.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := z.getAllAvailableZonesContext(ctx); err != nil {
+		return nil, err
+	}
+	return z.confZones()
+}
+
+// startingZones returns the set of zones the selector narrows, i.e. the zone(s) configured in the StorageClass
+// or all available zones in case the StorageClass doesn't configure any
+func (z *ZonesConf) startingZones() sets.String {
+	if z.isSCZoneConfigured || z.isSCZonesConfigured {
+		return z.scZones
+	}
+	return z.allAvailableZones
+}
+
+// narrow sets z.resultingZones to the zones and remembers the description of the constraint in case it narrowed z.resultingZones
+func (z *ZonesConf) narrow(constraint string, zones sets.String) {
+	if len(zones) < len(z.resultingZones) {
+		z.appliedConstraints = append(z.appliedConstraints, constraint)
+	}
+	z.resultingZones = zones
+}
+
+// AppliedConstraints returns descriptions of the selector constraints that narrowed the set of zones in the last ResolveZones call,
+// e.g. "matchLabels zone=us-east-1a" or "matchExpressions region NotIn [r2]", in the order they were applied.
+// Constraints that didn't narrow the set of zones are omitted.
+func (z *ZonesConf) AppliedConstraints() []string {
+	return append([]string{}, z.appliedConstraints...)
+}
+
 //START OMIT
 // GetConfZones returns:
 // - either a set of zones resulting from currently available zones, allowed zone(s) by an admin in the corresponding storage class and zones preferred by the user in the selector part of the PVC
@@ -619,18 +1810,30 @@ func (z *ZonesConf) GetConfZones() (sets.String, error) { // HL
 		z.resultingZones = z.resultingZones.Intersection(matchLabelZoneSet)
 	}
 	//END OMIT
+	if matchLabelZone, err := getPVCMatchLabel(z.PVC, metav1.LabelZoneFailureDomain); err == nil && len(z.resultingZones) < len(z.startingZones()) {
+		z.appliedConstraints = append(z.appliedConstraints, "matchLabels zone="+matchLabelZone)
+	}
+	if len(z.resultingZones) == 0 {
+		return nil, noSatisfyingZone("matchLabels zone")
+	}
 	if matchLabelRegion, err := getPVCMatchLabel(z.PVC, metav1.LabelZoneRegion); err == nil {
 		var zones sets.String
 		if zones, err = z.regionToZones(matchLabelRegion); err != nil {
 			return nil, err
 		}
-		z.resultingZones = z.resultingZones.Intersection(zones)
+		z.narrow("matchLabels region="+matchLabelRegion, z.resultingZones.Intersection(zones))
+	}
+	if len(z.resultingZones) == 0 {
+		return nil, noSatisfyingZone("matchLabels region")
 	}
 	if matchExpressionZoneSets, err := getPVCMatchExpression(z.PVC, metav1.LabelZoneFailureDomain, metav1.LabelSelectorOpIn); err == nil {
 		for _, matchExpressionZoneSet := range matchExpressionZoneSets {
-			z.resultingZones = z.resultingZones.Intersection(matchExpressionZoneSet)
+			z.narrow(fmt.Sprintf("matchExpressions zone In %v", matchExpressionZoneSet.List()), z.resultingZones.Intersection(matchExpressionZoneSet))
 		}
 	}
+	if len(z.resultingZones) == 0 {
+		return nil, noSatisfyingZone("matchExpressions zone In")
+	}
 	if matchExpressionRegionSets, err := getPVCMatchExpression(z.PVC, metav1.LabelZoneRegion, metav1.LabelSelectorOpIn); err == nil {
 		if !z.isRegionToZonesMapValid {
 			if err = z.calculateRegionToZonesMap(); err != nil {
@@ -643,14 +1846,20 @@ func (z *ZonesConf) GetConfZones() (sets.String, error) { // HL
 			for region := range matchExpressionRegionSet {
 				summedZonesForASetOfRegions = summedZonesForASetOfRegions.Union(z.regionToZonesMap[region])
 			}
-			z.resultingZones = z.resultingZones.Intersection(summedZonesForASetOfRegions)
+			z.narrow(fmt.Sprintf("matchExpressions region In %v", matchExpressionRegionSet.List()), z.resultingZones.Intersection(summedZonesForASetOfRegions))
 		}
 	}
+	if len(z.resultingZones) == 0 {
+		return nil, noSatisfyingZone("matchExpressions region In")
+	}
 	if matchExpressionZoneSets, err := getPVCMatchExpression(z.PVC, metav1.LabelZoneFailureDomain, metav1.LabelSelectorOpNotIn); err == nil {
 		for _, matchExpressionZoneSet := range matchExpressionZoneSets {
-			z.resultingZones = z.resultingZones.Difference(matchExpressionZoneSet)
+			z.narrow(fmt.Sprintf("matchExpressions zone NotIn %v", matchExpressionZoneSet.List()), z.resultingZones.Difference(matchExpressionZoneSet))
 		}
 	}
+	if len(z.resultingZones) == 0 {
+		return nil, noSatisfyingZone("matchExpressions zone NotIn")
+	}
 	if matchExpressionRegionSets, err := getPVCMatchExpression(z.PVC, metav1.LabelZoneRegion, metav1.LabelSelectorOpNotIn); err == nil {
 		if !z.isRegionToZonesMapValid {
 			if err = z.calculateRegionToZonesMap(); err != nil {
@@ -663,12 +1872,301 @@ func (z *ZonesConf) GetConfZones() (sets.String, error) { // HL
 			for region := range matchExpressionRegionSet {
 				summedZonesForASetOfRegions = summedZonesForASetOfRegions.Union(z.regionToZonesMap[region])
 			}
-			z.resultingZones = z.resultingZones.Difference(summedZonesForASetOfRegions)
+			z.narrow(fmt.Sprintf("matchExpressions region NotIn %v", matchExpressionRegionSet.List()), z.resultingZones.Difference(summedZonesForASetOfRegions))
 		}
 	}
 	if len(z.resultingZones) < 1 {
-		return nil, fmt.Errorf("Could not find availability zone: combination of StorageClass parameters and selector of this claim cannot be satisfied by this cluster")
+		return nil, noSatisfyingZone("matchExpressions region NotIn")
 	}
 
 	return z.resultingZones, nil
 }
+
+// ResolveZones returns:
+// - either the set of zones returned by GetConfZones narrowed to the DefaultZone in case neither the StorageClass parameters
+//   nor the selector part of the PVC narrow it, without the ExcludedZones and narrowed to a single region in case PreferSingleRegion is set
+// - or an error in case z is not valid, the resulting set of zones is empty or another error occurred
+// It should be preferred to GetConfZones, which ignores the DefaultZone, ExcludedZones and PreferSingleRegion and doesn't validate z.
+func (z *ZonesConf) ResolveZones() (sets.String, error) {
+	if err := z.Validate(); err != nil {
+		return nil, err
+	}
+	return z.confZones()
+}
+
+// confZones is the same as ResolveZones, except z is expected to be already validated
+func (z *ZonesConf) confZones() (sets.String, error) {
+	z.appliedConstraints = nil
+	if _, err := z.GetConfZones(); err != nil {
+		return nil, err
+	}
+	if emptySelector, _ := validatePVCSelector(z.PVC); emptySelector && !z.isSCZoneConfigured && !z.isSCZonesConfigured && z.DefaultZone != "" {
+		if _, err := z.defaultZone(); err != nil {
+			return nil, err
+		}
+	}
+	return z.finalZones()
+}
+
+// ChooseZone returns:
+// - either the zone ChooseZoneForVolume chooses for the pvcName from the set of zones returned by ResolveZones
+// - or an error in case ResolveZones failed
+func (z *ZonesConf) ChooseZone(pvcName string) (string, error) {
+	zones, err := z.ResolveZones()
+	if err != nil {
+		return "", err
+	}
+	return ChooseZoneForVolume(zones, pvcName), nil
+}
+
+// GetConfZonesLimited returns:
+// - either at most max alphabetically first zones of the set of zones returned by ResolveZones, e.g. for a provisioner that needs only a few candidate zones
+// - or an error in case max is not positive or ResolveZones failed
+func (z *ZonesConf) GetConfZonesLimited(max int) (sets.String, error) {
+	if max < 1 {
+		return nil, fmt.Errorf("the maximum number of zones must be positive, got %v", max)
+	}
+	zones, err := z.ResolveZones()
+	if err != nil {
+		return nil, err
+	}
+	return sets.NewString(FirstNZones(zones, max)...), nil
+}
+
+// ConfZonesResult holds the results of a single ResolveZones computation
+type ConfZonesResult struct {
+	// the set of zones returned by ResolveZones
+	Zones sets.String
+	// maps a single region to a set of all zones that are available in the region, it is nil in case the func ZoneToRegion is missing
+	RegionToZones map[string]sets.String
+	// the set of all available zones
+	AllAvailable sets.String
+}
+
+// GetConfZonesResult returns:
+// - either the result of ResolveZones together with the region map and the set of all available zones it was computed from, so that callers don't call the cloud provider again
+// - or an error in case ResolveZones or the func ZoneToRegion failed
+func (z *ZonesConf) GetConfZonesResult() (*ConfZonesResult, error) {
+	zones, err := z.ResolveZones()
+	if err != nil {
+		return nil, err
+	}
+	allAvailableZones, err := z.getAllAvailableZones()
+	if err != nil {
+		return nil, err
+	}
+	result := &ConfZonesResult{
+		Zones:        sets.NewString(zones.List()...),
+		AllAvailable: sets.NewString(allAvailableZones.List()...),
+	}
+	if z.ZoneToRegion != nil {
+		if err = z.calculateRegionToZonesMap(); err != nil {
+			return nil, err
+		}
+		result.RegionToZones = make(map[string]sets.String, len(z.regionToZonesMap))
+		for region, regionZones := range z.regionToZonesMap {
+			result.RegionToZones[region] = sets.NewString(regionZones.List()...)
+		}
+	}
+	return result, nil
+}
+
+// GetConfZonesByRegion returns:
+// - either the set of zones returned by ResolveZones grouped by their regions, e.g. for a UI that shows zones nested under regions
+// - or an error in case the func ZoneToRegion is missing, ResolveZones failed or the func ZoneToRegion failed
+func (z *ZonesConf) GetConfZonesByRegion() (map[string]sets.String, error) {
+	if z.ZoneToRegion == nil {
+		return nil, fmt.Errorf("func ZoneToRegion must be set in ZonesConf to group zones by region")
+	}
+	zones, err := z.ResolveZones()
+	if err != nil {
+		return nil, err
+	}
+	if err = z.calculateRegionToZonesMap(); err != nil {
+		return nil, err
+	}
+	ret := make(map[string]sets.String)
+	for region, regionZones := range z.regionToZonesMap {
+		if zonesInRegion := zones.Intersection(regionZones); len(zonesInRegion) > 0 {
+			ret[region] = zonesInRegion
+		}
+	}
+	// zones configured in the StorageClass need not be available, so they are missing in the region map
+	for zone := range zones {
+		if z.allAvailableZones.Has(zone) {
+			continue
+		}
+		region, err := z.ZoneToRegion(zone)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert zone (%v) to a region: %v", zone, err)
+		}
+		if _, ok := ret[region]; !ok {
+			ret[region] = make(sets.String)
+		}
+		ret[region].Insert(zone)
+	}
+	return ret, nil
+}
+
+// confZonesCacheSize is the maximum number of PVCs whose zones are cached by the func GetConfZonesCached
+const confZonesCacheSize = 1024
+
+// confZonesCacheEntry is a ResolveZones result for a single version of a PVC
+type confZonesCacheEntry struct {
+	pvcUID          types.UID
+	resourceVersion string
+	zones           sets.String
+}
+
+// confZonesCache is a size bounded LRU cache of ResolveZones results that is safe for concurrent use
+type confZonesCache struct {
+	lock    sync.Mutex
+	maxSize int
+	// maps a PVC UID to its element in the lru list
+	entries map[types.UID]*list.Element
+	// the most recently used entry is at the front of the list
+	lru *list.List
+}
+
+func newConfZonesCache(maxSize int) *confZonesCache {
+	return &confZonesCache{
+		maxSize: maxSize,
+		entries: make(map[types.UID]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+var defaultConfZonesCache = newConfZonesCache(confZonesCacheSize)
+
+// get returns the cached zones for the PVC version or false in case the PVC version is not cached
+func (c *confZonesCache) get(pvcUID types.UID, resourceVersion string) (sets.String, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	element, ok := c.entries[pvcUID]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*confZonesCacheEntry)
+	if entry.resourceVersion != resourceVersion {
+		// the PVC has changed since the zones were cached
+		c.lru.Remove(element)
+		delete(c.entries, pvcUID)
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	return sets.NewString(entry.zones.List()...), true
+}
+
+// add caches the zones for the PVC version, the least recently used entry is evicted in case the cache is full
+func (c *confZonesCache) add(pvcUID types.UID, resourceVersion string, zones sets.String) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry := &confZonesCacheEntry{pvcUID: pvcUID, resourceVersion: resourceVersion, zones: sets.NewString(zones.List()...)}
+	if element, ok := c.entries[pvcUID]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[pvcUID] = c.lru.PushFront(entry)
+	if c.lru.Len() > c.maxSize {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*confZonesCacheEntry).pvcUID)
+	}
+}
+
+// GetConfZonesCached returns the same as z.ResolveZones(), but the result is cached per PVC UID and resourceVersion,
+// so calling it again for the same version of the PVC doesn't recompute the zones.
+// The cached result is invalidated as soon as it is requested with a different resourceVersion of the PVC.
+// Errors are not cached.
+func GetConfZonesCached(z *ZonesConf, pvcUID types.UID, resourceVersion string) (sets.String, error) {
+	return defaultConfZonesCache.getConfZones(z, pvcUID, resourceVersion)
+}
+
+func (c *confZonesCache) getConfZones(z *ZonesConf, pvcUID types.UID, resourceVersion string) (sets.String, error) {
+	if zones, ok := c.get(pvcUID, resourceVersion); ok {
+		return zones, nil
+	}
+	zones, err := z.ResolveZones()
+	if err != nil {
+		return nil, err
+	}
+	c.add(pvcUID, resourceVersion, zones)
+	return zones, nil
+}
+
+// IntersectConfZones returns:
+// - either a set of zones that satisfy all the ZonesConfs, e.g. for two PVCs of a pod that must share a zone
+// - or an error in case there is no ZonesConf, the resulting set of zones is empty or another error occurred
+func IntersectConfZones(confs ...*ZonesConf) (sets.String, error) {
+	if len(confs) == 0 {
+		return nil, fmt.Errorf("no ZonesConf to intersect")
+	}
+	var ret sets.String
+	for i, conf := range confs {
+		zones, err := conf.ResolveZones()
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			ret = zones
+		} else {
+			ret = ret.Intersection(zones)
+		}
+	}
+	if len(ret) < 1 {
+		return nil, fmt.Errorf("%w: the claims have no zone in common", ErrNoSatisfyingZone)
+	}
+	return ret, nil
+}
+
+// UnionConfZones returns:
+// - either a set of zones that satisfy at least one of the ZonesConfs
+// - or an error in case there is no ZonesConf or any of the ZonesConfs can't be satisfied
+func UnionConfZones(confs ...*ZonesConf) (sets.String, error) {
+	if len(confs) == 0 {
+		return nil, fmt.Errorf("no ZonesConf to unite")
+	}
+	ret := make(sets.String)
+	for _, conf := range confs {
+		zones, err := conf.ResolveZones()
+		if err != nil {
+			return nil, err
+		}
+		ret = ret.Union(zones)
+	}
+	return ret, nil
+}
+
+// GetConfZonesUnion returns:
+// - either a set of available zones that are allowed by an admin in the corresponding storage class or preferred by the user in the selector part of the PVC
+// - or an error in case the resulting set of zones is empty or another error occurred
+// The result is advisory only, e.g. for suggesting zones in a UI, use ResolveZones to find out where a volume may be placed.
+func (z *ZonesConf) GetConfZonesUnion() (sets.String, error) {
+	if err := z.Validate(); err != nil {
+		return nil, err
+	}
+	allAvailableZones, err := z.getAllAvailableZones()
+	if err != nil {
+		return nil, err
+	}
+	scZones := allAvailableZones
+	if z.isSCZoneConfigured || z.isSCZonesConfigured {
+		scZones = z.scZones
+	}
+	// the selector is evaluated on a copy without the StorageClass parameters, so z is left untouched
+	selectorConf := *z
+	selectorConf.isSCZoneConfigured = false
+	selectorConf.isSCZonesConfigured = false
+	selectorConf.DefaultZone = ""
+	selectorConf.PreferSingleRegion = false
+	selectorZones, err := selectorConf.ResolveZones()
+	if err != nil && !stderrors.Is(err, ErrNoSatisfyingZone) {
+		return nil, err
+	}
+	ret := allAvailableZones.Intersection(scZones.Union(selectorZones)).Difference(z.ExcludedZones)
+	if len(ret) < 1 {
+		return nil, fmt.Errorf("%w: neither StorageClass parameters nor selector of this claim allow an available zone", ErrNoSatisfyingZone)
+	}
+	return ret, nil
+}
@@ -0,0 +1,2244 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/kubernetes/pkg/api/v1"
+	batchv1 "k8s.io/kubernetes/pkg/apis/batch/v1"
+)
+
+func TestZonePreferenceOrder(t *testing.T) {
+	functionUnderTest := "ZonePreferenceOrder"
+	zones := sets.NewString("us-east-1a", "us-east-1b", "us-east-1c", "us-east-1d")
+	for _, pvcName := range []string{"pvc", "claim-statefulset-0", "claim-statefulset-1", "claim-statefulset-2", "my-volume"} {
+		order := ZonePreferenceOrder(zones, pvcName)
+		if want := ChooseZoneForVolume(zones, pvcName); order[0] != want {
+			t.Errorf("%v(%v, %q) returned %v, want %q as the first zone", functionUnderTest, zones.List(), pvcName, order, want)
+		}
+		if len(order) != zones.Len() || !sets.NewString(order...).Equal(zones) {
+			t.Errorf("%v(%v, %q) returned %v, want a permutation of all zones", functionUnderTest, zones.List(), pvcName, order)
+		}
+	}
+
+	if order := ZonePreferenceOrder(sets.NewString(), "pvc"); len(order) != 0 {
+		t.Errorf("%v(%v, %q) returned %v, want an empty list", functionUnderTest, []string{}, "pvc", order)
+	}
+}
+
+type mockRecyclerClient struct {
+	pod            *v1.Pod
+	deletedCalled  bool
+	receivedEvents []mockEvent
+	events         []watch.Event
+}
+
+type mockEvent struct {
+	eventtype, message string
+}
+
+func (c *mockRecyclerClient) CreatePod(pod *v1.Pod) (*v1.Pod, error) {
+	if c.pod == nil {
+		c.pod = pod
+		return c.pod, nil
+	}
+	// Simulate "already exists" error
+	return nil, errors.NewAlreadyExists(schema.GroupResource{Resource: "pods"}, pod.Name)
+}
+
+func (c *mockRecyclerClient) GetPod(name, namespace string) (*v1.Pod, error) {
+	if c.pod != nil {
+		return c.pod, nil
+	}
+	return nil, fmt.Errorf("pod does not exist")
+}
+
+func (c *mockRecyclerClient) DeletePod(name, namespace string) error {
+	c.deletedCalled = true
+	return nil
+}
+
+func (c *mockRecyclerClient) WatchPod(name, namespace string, stopChannel chan struct{}) (<-chan watch.Event, error) {
+	eventCh := make(chan watch.Event, 0)
+	go func() {
+		for _, e := range c.events {
+			eventCh <- e
+		}
+	}()
+	return eventCh, nil
+}
+
+func (c *mockRecyclerClient) Event(eventtype, message string) {
+	c.receivedEvents = append(c.receivedEvents, mockEvent{eventtype, message})
+}
+
+func newRecyclerPod(name string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault},
+		Spec: v1.PodSpec{
+			RestartPolicy: v1.RestartPolicyNever,
+			Containers:    []v1.Container{{Name: "recycler", Image: "busybox"}},
+		},
+	}
+}
+
+func newPodEvent(eventtype watch.EventType, name string, phase v1.PodPhase, message string) watch.Event {
+	return watch.Event{
+		Type: eventtype,
+		Object: &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault},
+			Status: v1.PodStatus{
+				Phase:   phase,
+				Message: message,
+			},
+		},
+	}
+}
+
+func newEvent(eventtype, message string) watch.Event {
+	return watch.Event{
+		Type: watch.Added,
+		Object: &v1.Event{
+			ObjectMeta: metav1.ObjectMeta{Namespace: metav1.NamespaceDefault},
+			Reason:     "MockEvent",
+			Message:    message,
+			Type:       eventtype,
+		},
+	}
+}
+
+func TestRecyclerStats(t *testing.T) {
+	functionUnderTest := "internalRecycleVolumeByWatchingPodUntilCompletion"
+	client := &mockRecyclerClient{
+		events: []watch.Event{
+			newPodEvent(watch.Added, "podRecyclerStats", v1.PodPending, ""),
+			newEvent(v1.EventTypeNormal, "Pod was scheduled"),
+			newPodEvent(watch.Modified, "podRecyclerStats", v1.PodRunning, ""),
+			newPodEvent(watch.Modified, "podRecyclerStats", v1.PodSucceeded, ""),
+		},
+	}
+	pod := newRecyclerPod("podRecyclerStats")
+	stats, err := internalRecycleVolumeByWatchingPodUntilCompletion("pv-stats", pod, client)
+	if err != nil {
+		t.Fatalf("%v returned unexpected error: %v", functionUnderTest, err)
+	}
+	wantPodEvents := map[watch.EventType]int{watch.Added: 1, watch.Modified: 2}
+	if !reflect.DeepEqual(stats.PodEvents, wantPodEvents) {
+		t.Errorf("%v returned pod events %v, want %v", functionUnderTest, stats.PodEvents, wantPodEvents)
+	}
+	if stats.FinalPhase != v1.PodSucceeded {
+		t.Errorf("%v returned final phase %v, want %v", functionUnderTest, stats.FinalPhase, v1.PodSucceeded)
+	}
+	if len(client.receivedEvents) != 1 {
+		t.Errorf("%v forwarded %v events, want 1", functionUnderTest, len(client.receivedEvents))
+	}
+
+	client = &mockRecyclerClient{
+		events: []watch.Event{
+			newPodEvent(watch.Added, "podRecyclerStats", v1.PodPending, ""),
+			newPodEvent(watch.Deleted, "podRecyclerStats", v1.PodRunning, ""),
+		},
+	}
+	stats, err = internalRecycleVolumeByWatchingPodUntilCompletion("pv-stats", pod, client)
+	if err == nil {
+		t.Fatalf("%v returned no error, want an error", functionUnderTest)
+	}
+	wantPodEvents = map[watch.EventType]int{watch.Added: 1, watch.Deleted: 1}
+	if !reflect.DeepEqual(stats.PodEvents, wantPodEvents) {
+		t.Errorf("%v returned pod events %v, want %v", functionUnderTest, stats.PodEvents, wantPodEvents)
+	}
+	if stats.FinalPhase != v1.PodRunning {
+		t.Errorf("%v returned final phase %v, want %v", functionUnderTest, stats.FinalPhase, v1.PodRunning)
+	}
+}
+
+func TestRecyclerEventDedup(t *testing.T) {
+	functionUnderTest := "internalRecycleVolumeByWatchingPodUntilCompletion"
+	client := &mockRecyclerClient{
+		events: []watch.Event{
+			newEvent(v1.EventTypeNormal, "Pod was scheduled"),
+			newEvent(v1.EventTypeNormal, "Pod was scheduled"),
+			newEvent(v1.EventTypeWarning, "Pod was scheduled"),
+			newEvent(v1.EventTypeNormal, "Created pod"),
+			newEvent(v1.EventTypeNormal, "Pod was scheduled"),
+			newEvent(v1.EventTypeNormal, "Created pod"),
+			newPodEvent(watch.Modified, "podRecyclerDedup", v1.PodSucceeded, ""),
+		},
+	}
+	if _, err := internalRecycleVolumeByWatchingPodUntilCompletion("pv-dedup", newRecyclerPod("podRecyclerDedup"), client); err != nil {
+		t.Fatalf("%v returned unexpected error: %v", functionUnderTest, err)
+	}
+	want := []mockEvent{
+		{v1.EventTypeNormal, "Pod was scheduled"},
+		{v1.EventTypeWarning, "Pod was scheduled"},
+		{v1.EventTypeNormal, "Created pod"},
+	}
+	if !reflect.DeepEqual(client.receivedEvents, want) {
+		t.Errorf("%v forwarded events %v, want %v", functionUnderTest, client.receivedEvents, want)
+	}
+
+	// zero window forwards all events
+	events := client.events
+	client = &mockRecyclerClient{events: events}
+	noop := func(elapsed, total time.Duration) {}
+	if _, err := internalRecycleWithDeadline("pv-dedup", newRecyclerPod("podRecyclerDedup"), client, time.Minute, noop, 0); err != nil {
+		t.Fatalf("internalRecycleWithDeadline returned unexpected error: %v", err)
+	}
+	if len(client.receivedEvents) != len(events)-1 {
+		t.Errorf("internalRecycleWithDeadline with zero dedup window forwarded events %v, want all %v events", client.receivedEvents, len(events)-1)
+	}
+}
+
+func TestRecycleEventDeduperWindow(t *testing.T) {
+	now := time.Date(2017, time.October, 1, 12, 0, 0, 0, time.UTC)
+	deduper := newRecycleEventDeduper(10 * time.Second)
+	deduper.now = func() time.Time { return now }
+	tests := []struct {
+		after time.Duration
+		want  bool
+	}{
+		{0, true},
+		{5 * time.Second, false},
+		{10 * time.Second, true},
+		{19 * time.Second, false},
+	}
+	for _, test := range tests {
+		deduper.now = func() time.Time { return now.Add(test.after) }
+		if got := deduper.shouldForward(v1.EventTypeNormal, "Pod was scheduled"); got != test.want {
+			t.Errorf("shouldForward() after %v returned %v, want %v", test.after, got, test.want)
+		}
+	}
+
+	deduper = newRecycleEventDeduper(0)
+	for i := 0; i < 2; i++ {
+		if !deduper.shouldForward(v1.EventTypeNormal, "Pod was scheduled") {
+			t.Errorf("shouldForward() with zero window returned false, want true")
+		}
+	}
+}
+
+func TestRecyclerPodName(t *testing.T) {
+	functionUnderTest := "internalRecycleVolumeByWatchingPodUntilCompletion"
+	tests := []struct {
+		podName  string
+		oldPod   *v1.Pod
+		wantName string
+	}{
+		{
+			podName:  "",
+			wantName: "recycler-for-pv-name",
+		},
+		{
+			podName:  "custom-recycler",
+			wantName: "custom-recycler",
+		},
+		{
+			// an old pod with the custom name is adopted
+			podName:  "custom-recycler",
+			oldPod:   &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "custom-recycler", Namespace: metav1.NamespaceDefault, Labels: map[string]string{RecyclerPVLabel: "pv-name"}}},
+			wantName: "custom-recycler",
+		},
+	}
+	for _, test := range tests {
+		client := &mockRecyclerClient{
+			pod: test.oldPod,
+			events: []watch.Event{
+				newPodEvent(watch.Modified, test.wantName, v1.PodSucceeded, ""),
+			},
+		}
+		pod := newRecyclerPod(test.podName)
+		if _, err := internalRecycleVolumeByWatchingPodUntilCompletion("pv-name", pod, client); err != nil {
+			t.Errorf("%v(pod name %q) returned unexpected error: %v", functionUnderTest, test.podName, err)
+		}
+		if pod.Name != test.wantName {
+			t.Errorf("%v(pod name %q) used pod name %q, want %q", functionUnderTest, test.podName, pod.Name, test.wantName)
+		}
+		if test.oldPod != nil && client.pod != test.oldPod {
+			t.Errorf("%v(pod name %q) did not adopt the old pod", functionUnderTest, test.podName)
+		}
+		if !client.deletedCalled {
+			t.Errorf("%v(pod name %q) did not delete the recycler pod", functionUnderTest, test.podName)
+		}
+	}
+}
+
+func TestValidateStorageClassZoneParams(t *testing.T) {
+	functionUnderTest := "ValidateStorageClassZoneParams"
+	tests := []struct {
+		zone    string
+		zones   string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"us-east-1a", "", false},
+		{"", "us-east-1a", false},
+		{"", "us-east-1a, us-east-1b", false},
+		// both zone and zones
+		{"us-east-1a", "us-east-1b", true},
+		// zone containing a delimiter of a list of zones
+		{"us-east-1a,us-east-1b", "", true},
+		{"us-east-1a;us-east-1b", "", true},
+		{"us-east-1a us-east-1b", "", true},
+		{"us-east-1a\nus-east-1b", "", true},
+		{"us-east-1a\t", "", true},
+		// invalid comma separated list of zones
+		{"", "us-east-1a,,us-east-1b", true},
+		{"", ",", true},
+	}
+	for _, test := range tests {
+		if err := ValidateStorageClassZoneParams(test.zone, test.zones); (err != nil) != test.wantErr {
+			t.Errorf("%v(%q, %q) returned %v, want error: %v", functionUnderTest, test.zone, test.zones, err, test.wantErr)
+		}
+	}
+}
+
+func TestValidateZoneFormat(t *testing.T) {
+	functionUnderTest := "ValidateZoneFormat"
+	tests := []struct {
+		zone     string
+		provider string
+		wantErr  bool
+	}{
+		{"us-east-1a", "aws", false},
+		{"eu-central-1b", "aws", false},
+		{"us-gov-west-1a", "aws", false},
+		{"us-east-1", "aws", true},
+		{"us-east1-a", "aws", true},
+		{"us-east-1a ", "aws", true},
+		{"us-central1-a", "gce", false},
+		{"europe-west1-b", "gce", false},
+		{"us-central1a", "gce", true},
+		{"us-east-1a", "gce", true},
+		{"1", "azure", false},
+		{"3", "azure", false},
+		{"westeurope-1", "azure", true},
+		{"", "azure", true},
+		// unknown provider
+		{"us-east-1a", "openstack", true},
+	}
+	for _, test := range tests {
+		if err := ValidateZoneFormat(test.zone, test.provider); (err != nil) != test.wantErr {
+			t.Errorf("%v(%q, %q) returned %v, want error: %v", functionUnderTest, test.zone, test.provider, err, test.wantErr)
+		}
+	}
+}
+
+func TestGetConfZonesCached(t *testing.T) {
+	functionUnderTest := "GetConfZonesCached"
+	getAllZonesCalls := 0
+	newZonesConf := func() *ZonesConf {
+		return &ZonesConf{
+			PVC: &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"}},
+			GetAllZones: func() (sets.String, error) {
+				getAllZonesCalls++
+				return sets.NewString("us-east-1a", "us-east-1b"), nil
+			},
+		}
+	}
+	cache := newConfZonesCache(1)
+	tests := []struct {
+		pvcUID          types.UID
+		resourceVersion string
+		wantCalls       int
+	}{
+		{"uid1", "1", 1},
+		// same version is cached
+		{"uid1", "1", 1},
+		// a new version invalidates the cached result
+		{"uid1", "2", 2},
+		{"uid1", "2", 2},
+		// uid1 is evicted because of the cache size
+		{"uid2", "1", 3},
+		{"uid1", "2", 4},
+	}
+	for _, test := range tests {
+		zones, err := cache.getConfZones(newZonesConf(), test.pvcUID, test.resourceVersion)
+		if err != nil {
+			t.Errorf("%v(%v, %v) returned unexpected error: %v", functionUnderTest, test.pvcUID, test.resourceVersion, err)
+		} else if !zones.Equal(sets.NewString("us-east-1a", "us-east-1b")) {
+			t.Errorf("%v(%v, %v) returned %v, want %v", functionUnderTest, test.pvcUID, test.resourceVersion, zones.List(), []string{"us-east-1a", "us-east-1b"})
+		}
+		if getAllZonesCalls != test.wantCalls {
+			t.Errorf("%v(%v, %v) called GetAllZones %v times in total, want %v", functionUnderTest, test.pvcUID, test.resourceVersion, getAllZonesCalls, test.wantCalls)
+		}
+	}
+
+	// GetConfZonesCached uses the package cache, it is replaced by an empty one for the test and restored afterwards
+	defer func(cache *confZonesCache) {
+		defaultConfZonesCache = cache
+	}(defaultConfZonesCache)
+	defaultConfZonesCache = newConfZonesCache(confZonesCacheSize)
+	if _, err := GetConfZonesCached(newZonesConf(), "uid3", "1"); err != nil {
+		t.Errorf("%v(%v, %v) returned unexpected error: %v", functionUnderTest, "uid3", "1", err)
+	}
+	if _, err := GetConfZonesCached(newZonesConf(), "uid3", "1"); err != nil || getAllZonesCalls != 5 {
+		t.Errorf("%v(%v, %v) returned error %v and called GetAllZones %v times in total, want no error and %v calls", functionUnderTest, "uid3", "1", err, getAllZonesCalls, 5)
+	}
+}
+
+func TestRecycleWithDeadline(t *testing.T) {
+	functionUnderTest := "internalRecycleWithDeadline"
+	client := &mockRecyclerClient{
+		events: []watch.Event{
+			// the pod never completes
+			newPodEvent(watch.Added, "podRecyclerDeadline", v1.PodRunning, ""),
+		},
+	}
+	pod := newRecyclerPod("podRecyclerDeadline")
+	timeout := 100 * time.Millisecond
+	ticks := 0
+	onTick := func(elapsed, total time.Duration) {
+		ticks++
+		if total != timeout {
+			t.Errorf("%v called onTick with total %v, want %v", functionUnderTest, total, timeout)
+		}
+		if elapsed > total {
+			t.Errorf("%v called onTick with elapsed %v greater than total %v", functionUnderTest, elapsed, total)
+		}
+	}
+	if _, err := internalRecycleWithDeadline("pv-deadline", pod, client, timeout, onTick, DefaultRecycleEventDedupWindow); err == nil {
+		t.Errorf("%v returned no error, want a deadline error", functionUnderTest)
+	}
+	if ticks == 0 {
+		t.Errorf("%v did not call onTick", functionUnderTest)
+	}
+	if !client.deletedCalled {
+		t.Errorf("%v did not delete the recycler pod", functionUnderTest)
+	}
+}
+
+func TestZonesInRegion(t *testing.T) {
+	functionUnderTest := "ZonesInRegion"
+	z := ZonesConf{
+		GetAllZones: func() (sets.String, error) {
+			return sets.NewString("a", "b", "c"), nil
+		},
+		ZoneToRegion: func(zone string) (string, error) {
+			if zone == "c" {
+				return "r2", nil
+			}
+			return "r1", nil
+		},
+	}
+	tests := []struct {
+		region string
+		want   sets.String
+	}{
+		{"r1", sets.NewString("a", "b")},
+		{"r2", sets.NewString("c")},
+		{"unknown", sets.NewString()},
+	}
+	for _, test := range tests {
+		if zones, err := z.ZonesInRegion(test.region); err != nil || !zones.Equal(test.want) {
+			t.Errorf("%v(%q) returned (%v, %v), want (%v, %v)", functionUnderTest, test.region, zones.List(), err, test.want.List(), nil)
+		}
+	}
+
+	z.ZoneToRegion = func(zone string) (string, error) {
+		return "", fmt.Errorf("unknown zone %q", zone)
+	}
+	z.isRegionToZonesMapValid = false
+	if zones, err := z.ZonesInRegion("r1"); err == nil {
+		t.Errorf("%v(%q) returned (%v, %v), want an error", functionUnderTest, "r1", zones.List(), err)
+	}
+}
+
+func TestZoneDistribution(t *testing.T) {
+	functionUnderTest := "ZoneDistribution"
+	zones := sets.NewString("us-east-1a", "us-east-1b", "us-east-1c")
+	pvcNames := make([]string, 10000)
+	for i := range pvcNames {
+		pvcNames[i] = fmt.Sprintf("volume%d", i)
+	}
+	distribution := ZoneDistribution(zones, pvcNames)
+	total := 0
+	for zone, count := range distribution {
+		if !zones.Has(zone) {
+			t.Errorf("%v returned unknown zone %q", functionUnderTest, zone)
+		}
+		if count > len(pvcNames)*60/100 {
+			t.Errorf("%v placed %v of %v volumes in zone %q, want at most 60%%", functionUnderTest, count, len(pvcNames), zone)
+		}
+		total += count
+	}
+	if total != len(pvcNames) {
+		t.Errorf("%v placed %v volumes, want %v", functionUnderTest, total, len(pvcNames))
+	}
+	for _, pvcName := range pvcNames[:10] {
+		if zone := ChooseZoneForVolume(zones, pvcName); ZoneDistribution(zones, []string{pvcName})[zone] != 1 {
+			t.Errorf("%v(%v, %q) does not match ChooseZoneForVolume zone %q", functionUnderTest, zones.List(), pvcName, zone)
+		}
+	}
+}
+
+func TestChooseZoneForVolumeNoStatefulHeuristic(t *testing.T) {
+	functionUnderTest := "ChooseZoneForVolumeNoStatefulHeuristic"
+	zones := sets.NewString("us-east-1a", "us-east-1b", "us-east-1c")
+	// "backup-2023" looks like a StatefulSet volume, so ChooseZoneForVolume hashes only "backup"
+	if ChooseZoneForVolumeNoStatefulHeuristic(zones, "backup-2023") == ChooseZoneForVolume(zones, "backup-2023") {
+		t.Errorf("%v(%v, %q) returned the same zone as ChooseZoneForVolume, want a different zone", functionUnderTest, zones.List(), "backup-2023")
+	}
+	// names that don't look like StatefulSet volumes are placed the same way
+	for _, pvcName := range []string{"backup", "my-volume", "data-2023a"} {
+		if got, want := ChooseZoneForVolumeNoStatefulHeuristic(zones, pvcName), ChooseZoneForVolume(zones, pvcName); got != want {
+			t.Errorf("%v(%v, %q) returned %q, want %q", functionUnderTest, zones.List(), pvcName, got, want)
+		}
+	}
+}
+
+func TestChooseZoneForVolumeWithPin(t *testing.T) {
+	functionUnderTest := "ChooseZoneForVolumeWithPin"
+	zones := sets.NewString("us-east-1a", "us-east-1b", "us-east-1c")
+	tests := []struct {
+		pinnedZone string
+		want       string
+		wantErr    bool
+	}{
+		{"us-east-1b", "us-east-1b", false},
+		{"us-west-1a", "", true},
+		// empty pin falls back to ChooseZoneForVolume
+		{"", ChooseZoneForVolume(zones, "pvc"), false},
+	}
+	for _, test := range tests {
+		zone, err := ChooseZoneForVolumeWithPin(zones, "pvc", test.pinnedZone)
+		if zone != test.want || (err != nil) != test.wantErr {
+			t.Errorf("%v(%v, %q, %q) returned (%q, %v), want (%q, error: %v)", functionUnderTest, zones.List(), "pvc", test.pinnedZone, zone, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestResolveZonesDefaultZone(t *testing.T) {
+	functionUnderTest := "ResolveZones"
+	getAllZones := func() (sets.String, error) {
+		return sets.NewString("us-east-1a", "us-east-1b", "us-east-1c"), nil
+	}
+	emptySelectorPVC := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"}}
+	zoneSelectorPVC := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{metav1.LabelZoneFailureDomain: "us-east-1c"},
+			},
+		},
+	}
+	tests := []struct {
+		pvc         *v1.PersistentVolumeClaim
+		scZones     string
+		defaultZone string
+		want        sets.String
+	}{
+		{emptySelectorPVC, "", "", sets.NewString("us-east-1a", "us-east-1b", "us-east-1c")},
+		{emptySelectorPVC, "", "us-east-1b", sets.NewString("us-east-1b")},
+		// StorageClass parameters or selector take precedence over the default zone
+		{emptySelectorPVC, "us-east-1a, us-east-1c", "us-east-1b", sets.NewString("us-east-1a", "us-east-1c")},
+		{zoneSelectorPVC, "", "us-east-1b", sets.NewString("us-east-1c")},
+	}
+	for _, test := range tests {
+		z := ZonesConf{PVC: test.pvc, GetAllZones: getAllZones, DefaultZone: test.defaultZone}
+		if test.scZones != "" {
+			if err := z.SetZones(test.scZones); err != nil {
+				t.Fatalf("SetZones(%q) returned unexpected error: %v", test.scZones, err)
+			}
+		}
+		if zones, err := z.ResolveZones(); err != nil || !zones.Equal(test.want) {
+			t.Errorf("%v() with DefaultZone %q and zones %q returned (%v, %v), want (%v, %v)", functionUnderTest, test.defaultZone, test.scZones, zones.List(), err, test.want.List(), nil)
+		}
+	}
+
+	z := ZonesConf{PVC: emptySelectorPVC, GetAllZones: getAllZones, DefaultZone: "us-west-1a"}
+	if zones, err := z.ResolveZones(); err == nil {
+		t.Errorf("%v() with unavailable DefaultZone %q returned (%v, %v), want an error", functionUnderTest, "us-west-1a", zones.List(), err)
+	}
+
+	// GetConfZones ignores the default zone
+	z = ZonesConf{PVC: emptySelectorPVC, GetAllZones: getAllZones, DefaultZone: "us-east-1b"}
+	if zones, err := z.GetConfZones(); err != nil || !zones.Equal(sets.NewString("us-east-1a", "us-east-1b", "us-east-1c")) {
+		t.Errorf("GetConfZones() with DefaultZone %q returned (%v, %v), want (%v, %v)", "us-east-1b", zones.List(), err, []string{"us-east-1a", "us-east-1b", "us-east-1c"}, nil)
+	}
+}
+
+func TestZonesConfValidate(t *testing.T) {
+	functionUnderTest := "Validate"
+	getAllZones := func() (sets.String, error) {
+		return sets.NewString("us-east-1a"), nil
+	}
+	zoneToRegion := func(zone string) (string, error) {
+		return "us-east-1", nil
+	}
+	zonePVC := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{metav1.LabelZoneFailureDomain: "us-east-1a"},
+			},
+		},
+	}
+	regionPVC := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			Selector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{
+						Key:      metav1.LabelZoneRegion,
+						Operator: metav1.LabelSelectorOpIn,
+						Values:   []string{"us-east-1"},
+					},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		z       ZonesConf
+		wantErr bool
+	}{
+		{ZonesConf{PVC: zonePVC, GetAllZones: getAllZones, ZoneToRegion: zoneToRegion}, false},
+		// ZoneToRegion is needed only for a region in the selector
+		{ZonesConf{PVC: zonePVC, GetAllZones: getAllZones}, false},
+		{ZonesConf{PVC: regionPVC, GetAllZones: getAllZones, ZoneToRegion: zoneToRegion}, false},
+		{ZonesConf{PVC: regionPVC, GetAllZones: getAllZones}, true},
+		{ZonesConf{GetAllZones: getAllZones, ZoneToRegion: zoneToRegion}, true},
+		{ZonesConf{PVC: zonePVC, ZoneToRegion: zoneToRegion}, true},
+	}
+	for i, test := range tests {
+		if err := test.z.Validate(); (err != nil) != test.wantErr {
+			t.Errorf("%v() of test %v returned %v, want error: %v", functionUnderTest, i, err, test.wantErr)
+		}
+		if _, err := test.z.ResolveZones(); test.wantErr && err == nil {
+			t.Errorf("ResolveZones() of test %v returned no error, want an error", i)
+		}
+	}
+}
+
+func TestZonesToSet(t *testing.T) {
+	functionUnderTest := "zonesToSet"
+	succTests := []struct {
+		zones string
+		want  sets.String
+	}{
+		{"a", sets.NewString("a")},
+		{"a,b", sets.NewString("a", "b")},
+		{" a , b ", sets.NewString("a", "b")},
+		{"a;b", sets.NewString("a", "b")},
+		{"a b", sets.NewString("a", "b")},
+		{"a\nb", sets.NewString("a", "b")},
+		{"a\n\n\tb  c", sets.NewString("a", "b", "c")},
+		{"a, b\nc;d", sets.NewString("a", "b", "c", "d")},
+		{"a,\nb;\n c", sets.NewString("a", "b", "c")},
+	}
+	for _, test := range succTests {
+		if zones, err := zonesToSet(test.zones); err != nil || !zones.Equal(test.want) {
+			t.Errorf("%v(%q) returned (%v, %v), want (%v, %v)", functionUnderTest, test.zones, zones.List(), err, test.want.List(), nil)
+		}
+	}
+
+	for _, zones := range []string{"", " ", "\n", "a,,b", "a;;b", "a,;b", "a, \n,b", ",a", "a;"} {
+		if got, err := zonesToSet(zones); err == nil {
+			t.Errorf("%v(%q) returned (%v, %v), want an error", functionUnderTest, zones, got.List(), err)
+		}
+	}
+}
+
+func TestResolveZonesNoSatisfyingZone(t *testing.T) {
+	functionUnderTest := "ResolveZones"
+	z := ZonesConf{
+		PVC: &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+			Spec: v1.PersistentVolumeClaimSpec{
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{metav1.LabelZoneFailureDomain: "us-west-1a"},
+				},
+			},
+		},
+		GetAllZones: func() (sets.String, error) {
+			return sets.NewString("us-east-1a", "us-east-1b"), nil
+		},
+	}
+	if zones, err := z.ResolveZones(); !stderrors.Is(err, ErrNoSatisfyingZone) {
+		t.Errorf("%v() returned (%v, %v), want (%v, %v)", functionUnderTest, zones.List(), err, nil, ErrNoSatisfyingZone)
+	}
+}
+
+func TestZoneAndZones(t *testing.T) {
+	var z ZonesConf
+	if err := z.SetZone("us-east-1a"); err != nil {
+		t.Errorf("SetZone(%q) returned unexpected error: %v", "us-east-1a", err)
+	}
+	if err := z.SetZones("us-east-1a, us-east-1b"); !stderrors.Is(err, ErrZoneAndZones) {
+		t.Errorf("SetZones(%q) after SetZone returned %v, want %v", "us-east-1a, us-east-1b", err, ErrZoneAndZones)
+	}
+
+	z = ZonesConf{}
+	if err := z.SetZones("us-east-1a, us-east-1b"); err != nil {
+		t.Errorf("SetZones(%q) returned unexpected error: %v", "us-east-1a, us-east-1b", err)
+	}
+	if err := z.SetZone("us-east-1a"); !stderrors.Is(err, ErrZoneAndZones) {
+		t.Errorf("SetZone(%q) after SetZones returned %v, want %v", "us-east-1a", err, ErrZoneAndZones)
+	}
+
+	if err := ValidateStorageClassZoneParams("us-east-1a", "us-east-1b"); !stderrors.Is(err, ErrZoneAndZones) {
+		t.Errorf("ValidateStorageClassZoneParams(%q, %q) returned %v, want %v", "us-east-1a", "us-east-1b", err, ErrZoneAndZones)
+	}
+}
+
+func TestChooseZoneForVolumeColocated(t *testing.T) {
+	functionUnderTest := "ChooseZoneForVolumeColocated"
+	zones := sets.NewString("us-east-1a", "us-east-1b", "us-east-1c")
+	pvcName := "logs-web-1"
+	tests := []struct {
+		existingZone string
+		want         string
+	}{
+		{"us-east-1c", "us-east-1c"},
+		// fall back to ChooseZoneForVolume
+		{"", ChooseZoneForVolume(zones, pvcName)},
+		{"us-west-1a", ChooseZoneForVolume(zones, pvcName)},
+	}
+	for _, test := range tests {
+		if zone, err := ChooseZoneForVolumeColocated(zones, pvcName, test.existingZone); err != nil || zone != test.want {
+			t.Errorf("%v(%v, %q, %q) returned (%q, %v), want (%q, %v)", functionUnderTest, zones.List(), pvcName, test.existingZone, zone, err, test.want, nil)
+		}
+	}
+	if zone, err := ChooseZoneForVolumeColocated(sets.NewString(), pvcName, "us-east-1a"); err == nil {
+		t.Errorf("%v(%v, %q, %q) returned (%q, %v), want an error", functionUnderTest, []string{}, pvcName, "us-east-1a", zone, err)
+	}
+}
+
+func TestZoneChooser(t *testing.T) {
+	functionUnderTest := "Choose"
+	zones := sets.NewString("us-east-1a", "us-east-1b", "us-east-1c", "us-west-1a", "us-west-1b")
+	chooser := NewZoneChooser(zones)
+	for i := 0; i < 1000; i++ {
+		for _, pvcName := range []string{fmt.Sprintf("volume%d", i), fmt.Sprintf("data-web-%d", i)} {
+			if got, want := chooser.Choose(pvcName), ChooseZoneForVolume(zones, pvcName); got != want {
+				t.Errorf("%v(%q) returned %q, want %q", functionUnderTest, pvcName, got, want)
+			}
+		}
+	}
+}
+
+func benchmarkZones(count int) sets.String {
+	zones := sets.NewString()
+	for i := 0; i < count; i++ {
+		zones.Insert(fmt.Sprintf("zone-%d", i))
+	}
+	return zones
+}
+
+func benchmarkPVCNames(count int) []string {
+	pvcNames := make([]string, count)
+	for i := range pvcNames {
+		pvcNames[i] = fmt.Sprintf("volume%d", i)
+	}
+	return pvcNames
+}
+
+func BenchmarkChooseZoneForVolume(b *testing.B) {
+	zones := benchmarkZones(100)
+	pvcNames := benchmarkPVCNames(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, pvcName := range pvcNames {
+			ChooseZoneForVolume(zones, pvcName)
+		}
+	}
+}
+
+func BenchmarkZoneChooser(b *testing.B) {
+	zones := benchmarkZones(100)
+	pvcNames := benchmarkPVCNames(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		chooser := NewZoneChooser(zones)
+		for _, pvcName := range pvcNames {
+			chooser.Choose(pvcName)
+		}
+	}
+}
+
+func TestGetConfZonesContext(t *testing.T) {
+	functionUnderTest := "GetConfZonesContext"
+	pvc := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"}}
+	z := ZonesConf{
+		PVC: pvc,
+		GetAllZonesCtx: func(ctx context.Context) (sets.String, error) {
+			return sets.NewString("us-east-1a", "us-east-1b"), nil
+		},
+	}
+	if zones, err := z.GetConfZonesContext(context.Background()); err != nil || !zones.Equal(sets.NewString("us-east-1a", "us-east-1b")) {
+		t.Errorf("%v() returned (%v, %v), want (%v, %v)", functionUnderTest, zones.List(), err, []string{"us-east-1a", "us-east-1b"}, nil)
+	}
+
+	// the context is cancelled while the slow cloud call is in progress
+	ctx, cancel := context.WithCancel(context.Background())
+	z = ZonesConf{
+		PVC: pvc,
+		GetAllZonesCtx: func(ctx context.Context) (sets.String, error) {
+			cancel()
+			<-ctx.Done()
+			return nil, fmt.Errorf("cloud call interrupted")
+		},
+	}
+	if zones, err := z.GetConfZonesContext(ctx); err != context.Canceled {
+		t.Errorf("%v() returned (%v, %v), want (%v, %v)", functionUnderTest, zones.List(), err, nil, context.Canceled)
+	}
+
+	// the func GetAllZones is used as a fallback
+	ctx, cancel = context.WithCancel(context.Background())
+	z = ZonesConf{
+		PVC: pvc,
+		GetAllZones: func() (sets.String, error) {
+			cancel()
+			return sets.NewString("us-east-1a"), nil
+		},
+	}
+	if zones, err := z.GetConfZonesContext(ctx); err != context.Canceled {
+		t.Errorf("%v() returned (%v, %v), want (%v, %v)", functionUnderTest, zones.List(), err, nil, context.Canceled)
+	}
+
+	// the func GetAllZones is not waited for once the context is cancelled
+	ctx, cancel = context.WithCancel(context.Background())
+	release := make(chan struct{})
+	defer close(release)
+	z = ZonesConf{
+		PVC: pvc,
+		GetAllZones: func() (sets.String, error) {
+			cancel()
+			<-release
+			return sets.NewString("us-east-1a"), nil
+		},
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := z.GetConfZonesContext(ctx)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("%v() returned error %v, want %v", functionUnderTest, err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("%v() didn't return after the context was cancelled", functionUnderTest)
+	}
+}
+
+func TestIntersectAndUnionConfZones(t *testing.T) {
+	getAllZones := func() (sets.String, error) {
+		return sets.NewString("us-east-1a", "us-east-1b", "us-east-1c", "us-east-1d"), nil
+	}
+	newZonesConf := func(zones ...string) *ZonesConf {
+		return &ZonesConf{
+			PVC: &v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+				Spec: v1.PersistentVolumeClaimSpec{
+					Selector: &metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{
+								Key:      metav1.LabelZoneFailureDomain,
+								Operator: metav1.LabelSelectorOpIn,
+								Values:   zones,
+							},
+						},
+					},
+				},
+			},
+			GetAllZones: getAllZones,
+		}
+	}
+
+	want := sets.NewString("us-east-1b")
+	if zones, err := IntersectConfZones(newZonesConf("us-east-1a", "us-east-1b"), newZonesConf("us-east-1b", "us-east-1c")); err != nil || !zones.Equal(want) {
+		t.Errorf("IntersectConfZones returned (%v, %v), want (%v, %v)", zones.List(), err, want.List(), nil)
+	}
+	want = sets.NewString("us-east-1a", "us-east-1b", "us-east-1c")
+	if zones, err := UnionConfZones(newZonesConf("us-east-1a", "us-east-1b"), newZonesConf("us-east-1b", "us-east-1c")); err != nil || !zones.Equal(want) {
+		t.Errorf("UnionConfZones returned (%v, %v), want (%v, %v)", zones.List(), err, want.List(), nil)
+	}
+
+	if zones, err := IntersectConfZones(newZonesConf("us-east-1a"), newZonesConf("us-east-1c")); !stderrors.Is(err, ErrNoSatisfyingZone) {
+		t.Errorf("IntersectConfZones returned (%v, %v), want (%v, %v)", zones.List(), err, nil, ErrNoSatisfyingZone)
+	}
+	if zones, err := IntersectConfZones(); err == nil {
+		t.Errorf("IntersectConfZones() returned (%v, %v), want an error", zones.List(), err)
+	}
+	if zones, err := UnionConfZones(newZonesConf("us-east-1a"), newZonesConf("us-west-1a")); err == nil {
+		t.Errorf("UnionConfZones returned (%v, %v), want an error", zones.List(), err)
+	}
+}
+
+func TestSelectorUsesRegions(t *testing.T) {
+	functionUnderTest := "SelectorUsesRegions"
+	tests := []struct {
+		selector *metav1.LabelSelector
+		want     bool
+	}{
+		{nil, false},
+		{
+			&metav1.LabelSelector{
+				MatchLabels: map[string]string{metav1.LabelZoneFailureDomain: "us-east-1a"},
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{
+						Key:      metav1.LabelZoneFailureDomain,
+						Operator: metav1.LabelSelectorOpNotIn,
+						Values:   []string{"us-east-1b"},
+					},
+				},
+			},
+			false,
+		},
+		{
+			&metav1.LabelSelector{
+				MatchLabels: map[string]string{metav1.LabelZoneRegion: "us-east-1"},
+			},
+			true,
+		},
+		{
+			&metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{
+						Key:      metav1.LabelZoneRegion,
+						Operator: metav1.LabelSelectorOpIn,
+						Values:   []string{"us-east-1"},
+					},
+				},
+			},
+			true,
+		},
+	}
+	for _, test := range tests {
+		pvc := &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+			Spec:       v1.PersistentVolumeClaimSpec{Selector: test.selector},
+		}
+		if got := SelectorUsesRegions(pvc); got != test.want {
+			t.Errorf("%v(%v) returned %v, want %v", functionUnderTest, test.selector, got, test.want)
+		}
+	}
+
+	// ZoneToRegion is never called for a selector without regions
+	z := ZonesConf{
+		PVC: &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+			Spec:       v1.PersistentVolumeClaimSpec{Selector: tests[1].selector},
+		},
+		GetAllZones: func() (sets.String, error) {
+			return sets.NewString("us-east-1a", "us-east-1b"), nil
+		},
+		ZoneToRegion: func(zone string) (string, error) {
+			t.Errorf("ZoneToRegion(%q) called for a selector without regions", zone)
+			return "us-east-1", nil
+		},
+	}
+	if zones, err := z.ResolveZones(); err != nil || !zones.Equal(sets.NewString("us-east-1a")) {
+		t.Errorf("ResolveZones() returned (%v, %v), want (%v, %v)", zones.List(), err, []string{"us-east-1a"}, nil)
+	}
+}
+
+func TestResolveZonesEarlyExit(t *testing.T) {
+	functionUnderTest := "ResolveZones"
+	zoneToRegionCalls := 0
+	z := ZonesConf{
+		PVC: &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+			Spec: v1.PersistentVolumeClaimSpec{
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{metav1.LabelZoneFailureDomain: "us-west-1a"},
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{
+							Key:      metav1.LabelZoneRegion,
+							Operator: metav1.LabelSelectorOpIn,
+							Values:   []string{"us-east-1"},
+						},
+					},
+				},
+			},
+		},
+		GetAllZones: func() (sets.String, error) {
+			return sets.NewString("us-east-1a", "us-east-1b"), nil
+		},
+		ZoneToRegion: func(zone string) (string, error) {
+			zoneToRegionCalls++
+			return "us-east-1", nil
+		},
+	}
+	if zones, err := z.ResolveZones(); !stderrors.Is(err, ErrNoSatisfyingZone) {
+		t.Errorf("%v() returned (%v, %v), want (%v, %v)", functionUnderTest, zones.List(), err, nil, ErrNoSatisfyingZone)
+	}
+	if zoneToRegionCalls != 0 {
+		t.Errorf("%v() called ZoneToRegion %v times, want 0", functionUnderTest, zoneToRegionCalls)
+	}
+}
+
+func TestRecyclerRestartPolicy(t *testing.T) {
+	functionUnderTest := "internalRecycleVolumeByWatchingPodUntilCompletion"
+	tests := []struct {
+		restartPolicy v1.RestartPolicy
+		wantErr       bool
+	}{
+		{v1.RestartPolicyNever, false},
+		{v1.RestartPolicyOnFailure, false},
+		{v1.RestartPolicyAlways, true},
+		// the API server defaults an empty restart policy to Always
+		{"", true},
+	}
+	for _, test := range tests {
+		client := &mockRecyclerClient{
+			events: []watch.Event{
+				newPodEvent(watch.Modified, "podRecyclerRestartPolicy", v1.PodSucceeded, ""),
+			},
+		}
+		pod := newRecyclerPod("podRecyclerRestartPolicy")
+		pod.Spec.RestartPolicy = test.restartPolicy
+		_, err := internalRecycleVolumeByWatchingPodUntilCompletion("pv-restart-policy", pod, client)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v(restart policy %q) returned %v, want error: %v", functionUnderTest, test.restartPolicy, err, test.wantErr)
+		}
+		if test.wantErr && client.pod != nil {
+			t.Errorf("%v(restart policy %q) created the recycler pod, want no pod", functionUnderTest, test.restartPolicy)
+		}
+	}
+}
+
+func TestRecyclerAdoptsOnlyLabeledPod(t *testing.T) {
+	functionUnderTest := "internalRecycleVolumeByWatchingPodUntilCompletion"
+	tests := []struct {
+		oldPodLabels map[string]string
+		podLabels    map[string]string
+		wantErr      bool
+	}{
+		{map[string]string{RecyclerPVLabel: "pv-label"}, nil, false},
+		{map[string]string{RecyclerPVLabel: "pv-label"}, map[string]string{"app": "recycler"}, false},
+		// a pod without the label is foreign
+		{nil, nil, true},
+		{map[string]string{"app": "recycler"}, nil, true},
+		{map[string]string{RecyclerPVLabel: "another-pv"}, nil, true},
+		// the label of the caller does not matter, the pod is tied to the recycled PV
+		{map[string]string{RecyclerPVLabel: "another-pv"}, map[string]string{RecyclerPVLabel: "another-pv"}, true},
+	}
+	for _, test := range tests {
+		oldPod := newRecyclerPod("podRecyclerLabel")
+		oldPod.Labels = test.oldPodLabels
+		client := &mockRecyclerClient{
+			pod: oldPod,
+			events: []watch.Event{
+				newPodEvent(watch.Modified, "podRecyclerLabel", v1.PodSucceeded, ""),
+			},
+		}
+		pod := newRecyclerPod("podRecyclerLabel")
+		pod.Labels = test.podLabels
+		_, err := internalRecycleVolumeByWatchingPodUntilCompletion("pv-label", pod, client)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v(old pod labels %v, pod labels %v) returned %v, want error: %v", functionUnderTest, test.oldPodLabels, test.podLabels, err, test.wantErr)
+		}
+		if test.wantErr && client.deletedCalled {
+			t.Errorf("%v(old pod labels %v, pod labels %v) deleted a foreign pod", functionUnderTest, test.oldPodLabels, test.podLabels)
+		}
+	}
+
+	// a new pod carries the label
+	client := &mockRecyclerClient{
+		events: []watch.Event{
+			newPodEvent(watch.Modified, "podRecyclerLabel", v1.PodSucceeded, ""),
+		},
+	}
+	if _, err := internalRecycleVolumeByWatchingPodUntilCompletion("pv-label", newRecyclerPod("podRecyclerLabel"), client); err != nil {
+		t.Fatalf("%v returned unexpected error: %v", functionUnderTest, err)
+	}
+	if pvName := client.pod.Labels[RecyclerPVLabel]; pvName != "pv-label" {
+		t.Errorf("%v created a pod with label %v=%q, want %q", functionUnderTest, RecyclerPVLabel, pvName, "pv-label")
+	}
+}
+
+func TestRecycleVolumeViaJobAdoptsOnlyLabeledJob(t *testing.T) {
+	functionUnderTest := "internalRecycleVolumeViaJob"
+	tests := []struct {
+		oldJobLabels map[string]string
+		wantErr      bool
+	}{
+		{map[string]string{RecyclerPVLabel: "pv-job"}, false},
+		{nil, true},
+		{map[string]string{RecyclerPVLabel: "another-pv"}, true},
+	}
+	for _, test := range tests {
+		oldJob := newRecyclerJob("recycler-for-pv-job")
+		oldJob.Labels = test.oldJobLabels
+		client := &mockRecyclerJobClient{
+			job: oldJob,
+			events: []watch.Event{
+				newJobEvent(watch.Modified, "recycler-for-pv-job", batchv1.JobComplete, ""),
+			},
+		}
+		_, err := internalRecycleVolumeViaJob("pv-job", newRecyclerJob(""), client)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v(old job labels %v) returned %v, want error: %v", functionUnderTest, test.oldJobLabels, err, test.wantErr)
+		}
+		if test.wantErr && client.deletedCalled {
+			t.Errorf("%v(old job labels %v) deleted a foreign job", functionUnderTest, test.oldJobLabels)
+		}
+	}
+}
+
+func TestGetConfZonesUnion(t *testing.T) {
+	newZonesConf := func(scZones string, selectorZones ...string) *ZonesConf {
+		z := &ZonesConf{
+			PVC: &v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+				Spec: v1.PersistentVolumeClaimSpec{
+					Selector: &metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{
+								Key:      metav1.LabelZoneFailureDomain,
+								Operator: metav1.LabelSelectorOpIn,
+								Values:   selectorZones,
+							},
+						},
+					},
+				},
+			},
+			GetAllZones: func() (sets.String, error) {
+				return sets.NewString("us-east-1a", "us-east-1b", "us-east-1c", "us-east-1d"), nil
+			},
+		}
+		if err := z.SetZones(scZones); err != nil {
+			t.Fatalf("SetZones(%q) returned %v", scZones, err)
+		}
+		return z
+	}
+	tests := []struct {
+		scZones       string
+		selectorZones []string
+		intersection  []string
+		union         []string
+	}{
+		{"us-east-1a,us-east-1b", []string{"us-east-1b", "us-east-1c"}, []string{"us-east-1b"}, []string{"us-east-1a", "us-east-1b", "us-east-1c"}},
+		{"us-east-1a", []string{"us-east-1c"}, nil, []string{"us-east-1a", "us-east-1c"}},
+		// unavailable zones are filtered out
+		{"us-east-1a,us-west-1a", []string{"us-west-1b"}, nil, []string{"us-east-1a"}},
+	}
+	for _, test := range tests {
+		zones, err := newZonesConf(test.scZones, test.selectorZones...).ResolveZones()
+		if test.intersection == nil {
+			if !stderrors.Is(err, ErrNoSatisfyingZone) {
+				t.Errorf("ResolveZones() for %q and %v returned (%v, %v), want (%v, %v)", test.scZones, test.selectorZones, zones.List(), err, nil, ErrNoSatisfyingZone)
+			}
+		} else if err != nil || !zones.Equal(sets.NewString(test.intersection...)) {
+			t.Errorf("ResolveZones() for %q and %v returned (%v, %v), want (%v, %v)", test.scZones, test.selectorZones, zones.List(), err, test.intersection, nil)
+		}
+		z := newZonesConf(test.scZones, test.selectorZones...)
+		if zones, err = z.GetConfZonesUnion(); err != nil || !zones.Equal(sets.NewString(test.union...)) {
+			t.Errorf("GetConfZonesUnion() for %q and %v returned (%v, %v), want (%v, %v)", test.scZones, test.selectorZones, zones.List(), err, test.union, nil)
+		}
+		// GetConfZonesUnion leaves the ZonesConf usable for GetConfZones
+		if zones, err = z.ResolveZones(); test.intersection != nil && (err != nil || !zones.Equal(sets.NewString(test.intersection...))) {
+			t.Errorf("ResolveZones() after GetConfZonesUnion() for %q and %v returned (%v, %v), want (%v, %v)", test.scZones, test.selectorZones, zones.List(), err, test.intersection, nil)
+		}
+	}
+
+	z := newZonesConf("us-west-1a", "us-west-1b")
+	if zones, err := z.GetConfZonesUnion(); !stderrors.Is(err, ErrNoSatisfyingZone) {
+		t.Errorf("GetConfZonesUnion() returned (%v, %v), want (%v, %v)", zones.List(), err, nil, ErrNoSatisfyingZone)
+	}
+}
+
+func TestZonesToNodeAffinity(t *testing.T) {
+	functionUnderTest := "ZonesToNodeAffinity"
+	zones := sets.NewString("us-east-1c", "us-east-1a", "us-east-1b")
+	want := &v1.VolumeNodeAffinity{
+		Required: &v1.NodeSelector{
+			NodeSelectorTerms: []v1.NodeSelectorTerm{
+				{
+					MatchExpressions: []v1.NodeSelectorRequirement{
+						{
+							Key:      metav1.LabelZoneFailureDomain,
+							Operator: v1.NodeSelectorOpIn,
+							Values:   []string{"us-east-1a", "us-east-1b", "us-east-1c"},
+						},
+					},
+				},
+			},
+		},
+	}
+	if got := ZonesToNodeAffinity(zones); !reflect.DeepEqual(got, want) {
+		t.Errorf("%v(%v) returned %+v, want %+v", functionUnderTest, zones.List(), got, want)
+	}
+}
+
+func TestNodeAffinityToZones(t *testing.T) {
+	functionUnderTest := "NodeAffinityToZones"
+	for _, zones := range []sets.String{sets.NewString("us-east-1a"), sets.NewString("us-east-1c", "us-east-1a", "us-east-1b")} {
+		if got, err := NodeAffinityToZones(ZonesToNodeAffinity(zones)); err != nil || !got.Equal(zones) {
+			t.Errorf("%v(ZonesToNodeAffinity(%v)) returned (%v, %v), want (%v, %v)", functionUnderTest, zones.List(), got.List(), err, zones.List(), nil)
+		}
+	}
+
+	newAffinity := func(reqs ...v1.NodeSelectorRequirement) *v1.VolumeNodeAffinity {
+		return &v1.VolumeNodeAffinity{
+			Required: &v1.NodeSelector{
+				NodeSelectorTerms: []v1.NodeSelectorTerm{{MatchExpressions: reqs}},
+			},
+		}
+	}
+	errTests := []*v1.VolumeNodeAffinity{
+		nil,
+		{},
+		newAffinity(),
+		newAffinity(v1.NodeSelectorRequirement{Key: metav1.LabelZoneFailureDomain, Operator: v1.NodeSelectorOpNotIn, Values: []string{"us-east-1a"}}),
+		newAffinity(v1.NodeSelectorRequirement{Key: metav1.LabelZoneRegion, Operator: v1.NodeSelectorOpIn, Values: []string{"us-east-1"}}),
+	}
+	for _, aff := range errTests {
+		if got, err := NodeAffinityToZones(aff); err == nil {
+			t.Errorf("%v(%+v) returned (%v, %v), want an error", functionUnderTest, aff, got.List(), err)
+		}
+	}
+}
+
+func TestGenerateVolumeNameUnique(t *testing.T) {
+	functionUnderTest := "GenerateVolumeNameUnique"
+	// both cluster names are cut to "cl" at this maxLength
+	maxLength := 12
+	first := GenerateVolumeName("cluster1", "pv-name-1", maxLength)
+	if second := GenerateVolumeName("cluster2", "pv-name-1", maxLength); first != second {
+		t.Fatalf("GenerateVolumeName returned %q and %q, want a collision", first, second)
+	}
+	existing := sets.NewString(first)
+	exists := func(name string) bool {
+		return existing.Has(name)
+	}
+
+	name, err := GenerateVolumeNameUnique("cluster2", "pv-name-1", maxLength, exists)
+	if err != nil || name == first || len(name) > maxLength {
+		t.Errorf("%v() returned (%q, %v), want a name different from %q that fits %v characters", functionUnderTest, name, err, first, maxLength)
+	}
+	if again, err := GenerateVolumeNameUnique("cluster2", "pv-name-1", maxLength, exists); err != nil || again != name {
+		t.Errorf("%v() returned (%q, %v), want the deterministic name (%q, %v)", functionUnderTest, again, err, name, nil)
+	}
+	if name, err := GenerateVolumeNameUnique("cluster3", "pv-name-3", maxLength, exists); err != nil || name != "cl-pv-name-3" {
+		t.Errorf("%v() returned (%q, %v), want (%q, %v)", functionUnderTest, name, err, "cl-pv-name-3", nil)
+	}
+	always := func(string) bool { return true }
+	if name, err := GenerateVolumeNameUnique("cluster2", "pv-name-1", maxLength, always); err == nil {
+		t.Errorf("%v() returned (%q, %v), want an error", functionUnderTest, name, err)
+	}
+}
+
+type mockRecyclerJobClient struct {
+	job            *batchv1.Job
+	deletedCalled  bool
+	receivedEvents []mockEvent
+	events         []watch.Event
+}
+
+func (c *mockRecyclerJobClient) CreateJob(job *batchv1.Job) (*batchv1.Job, error) {
+	if c.job == nil {
+		c.job = job
+		return c.job, nil
+	}
+	// Simulate "already exists" error
+	return nil, errors.NewAlreadyExists(schema.GroupResource{Resource: "jobs"}, job.Name)
+}
+
+func (c *mockRecyclerJobClient) GetJob(name, namespace string) (*batchv1.Job, error) {
+	if c.job != nil {
+		return c.job, nil
+	}
+	return nil, fmt.Errorf("job does not exist")
+}
+
+func (c *mockRecyclerJobClient) DeleteJob(name, namespace string) error {
+	c.deletedCalled = true
+	return nil
+}
+
+func (c *mockRecyclerJobClient) WatchJob(name, namespace string, stopChannel chan struct{}) (<-chan watch.Event, error) {
+	eventCh := make(chan watch.Event, 0)
+	go func() {
+		for _, e := range c.events {
+			eventCh <- e
+		}
+	}()
+	return eventCh, nil
+}
+
+func (c *mockRecyclerJobClient) Event(eventtype, message string) {
+	c.receivedEvents = append(c.receivedEvents, mockEvent{eventtype, message})
+}
+
+func newJobEvent(eventtype watch.EventType, name string, conditionType batchv1.JobConditionType, message string) watch.Event {
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault}}
+	if conditionType != "" {
+		job.Status.Conditions = []batchv1.JobCondition{{Type: conditionType, Status: v1.ConditionTrue, Message: message}}
+	}
+	return watch.Event{Type: eventtype, Object: job}
+}
+
+func newRecyclerJob(name string) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault},
+		Spec: batchv1.JobSpec{
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					RestartPolicy: v1.RestartPolicyNever,
+					Containers:    []v1.Container{{Name: "recycler", Image: "busybox"}},
+				},
+			},
+		},
+	}
+}
+
+func TestRecycleVolumeViaJob(t *testing.T) {
+	functionUnderTest := "internalRecycleVolumeViaJob"
+	tests := []struct {
+		name    string
+		events  []watch.Event
+		wantErr string
+	}{
+		{
+			name: "success",
+			events: []watch.Event{
+				newJobEvent(watch.Added, "recycler-for-pv-job", "", ""),
+				newEvent(v1.EventTypeNormal, "Created pod"),
+				newJobEvent(watch.Modified, "recycler-for-pv-job", batchv1.JobComplete, ""),
+			},
+		},
+		{
+			name: "failure",
+			events: []watch.Event{
+				newJobEvent(watch.Added, "recycler-for-pv-job", "", ""),
+				newJobEvent(watch.Modified, "recycler-for-pv-job", batchv1.JobFailed, "Job has reached the specified backoff limit"),
+			},
+			wantErr: "Job has reached the specified backoff limit",
+		},
+		{
+			name: "failure message with percent",
+			events: []watch.Event{
+				newJobEvent(watch.Modified, "recycler-for-pv-job", batchv1.JobFailed, "100% of the pods failed"),
+			},
+			wantErr: "100% of the pods failed",
+		},
+		{
+			name: "deleted",
+			events: []watch.Event{
+				newJobEvent(watch.Deleted, "recycler-for-pv-job", "", ""),
+			},
+			wantErr: "recycler job was deleted",
+		},
+	}
+	for _, test := range tests {
+		client := &mockRecyclerJobClient{events: test.events}
+		_, err := internalRecycleVolumeViaJob("pv-job", newRecyclerJob(""), client)
+		if test.wantErr == "" && err != nil {
+			t.Errorf("%v(%v) returned unexpected error: %v", functionUnderTest, test.name, err)
+		}
+		if test.wantErr != "" && (err == nil || err.Error() != test.wantErr) {
+			t.Errorf("%v(%v) returned %v, want error %q", functionUnderTest, test.name, err, test.wantErr)
+		}
+		if client.job == nil || client.job.Name != "recycler-for-pv-job" {
+			t.Errorf("%v(%v) created job %v, want job %q", functionUnderTest, test.name, client.job, "recycler-for-pv-job")
+		}
+		if !client.deletedCalled {
+			t.Errorf("%v(%v) did not delete the recycler job", functionUnderTest, test.name)
+		}
+	}
+}
+
+func TestRecycleVolumeViaJobStats(t *testing.T) {
+	functionUnderTest := "internalRecycleVolumeViaJob"
+	client := &mockRecyclerJobClient{
+		events: []watch.Event{
+			newJobEvent(watch.Added, "recycler-for-pv-job", "", ""),
+			newEvent(v1.EventTypeNormal, "Created pod"),
+			newJobEvent(watch.Modified, "recycler-for-pv-job", "", ""),
+			newJobEvent(watch.Modified, "recycler-for-pv-job", batchv1.JobComplete, ""),
+		},
+	}
+	stats, err := internalRecycleVolumeViaJob("pv-job", newRecyclerJob(""), client)
+	if err != nil {
+		t.Fatalf("%v returned unexpected error: %v", functionUnderTest, err)
+	}
+	wantEvents := map[watch.EventType]int{watch.Added: 1, watch.Modified: 2}
+	if !reflect.DeepEqual(stats.PodEvents, wantEvents) {
+		t.Errorf("%v returned job events %v, want %v", functionUnderTest, stats.PodEvents, wantEvents)
+	}
+
+	// the pod template of the job would never complete
+	client = &mockRecyclerJobClient{}
+	job := newRecyclerJob("")
+	job.Spec.Template.Spec.RestartPolicy = v1.RestartPolicyAlways
+	if _, err := internalRecycleVolumeViaJob("pv-job", job, client); err == nil {
+		t.Errorf("%v(restart policy %q) returned no error, want an error", functionUnderTest, v1.RestartPolicyAlways)
+	}
+	if client.job != nil {
+		t.Errorf("%v(restart policy %q) created the recycler job, want no job", functionUnderTest, v1.RestartPolicyAlways)
+	}
+}
+
+func TestRecyclerJobDeleteOptions(t *testing.T) {
+	functionUnderTest := "recyclerJobDeleteOptions"
+	options := recyclerJobDeleteOptions()
+	if options == nil || options.PropagationPolicy == nil || *options.PropagationPolicy != metav1.DeletePropagationBackground {
+		t.Errorf("%v() returned %+v, want propagation policy %q", functionUnderTest, options, metav1.DeletePropagationBackground)
+	}
+}
+
+func TestSortedZones(t *testing.T) {
+	zones := sets.NewString("us-east-1c", "us-east-1a", "us-west-1a", "us-east-1b")
+	want := []string{"us-east-1a", "us-east-1b", "us-east-1c", "us-west-1a"}
+	if got := SortedZones(zones); !reflect.DeepEqual(got, want) {
+		t.Errorf("SortedZones(%v) returned %v, want %v", zones, got, want)
+	}
+	if got := SortedZones(sets.NewString()); len(got) != 0 {
+		t.Errorf("SortedZones() returned %v, want no zones", got)
+	}
+}
+
+func TestFirstNZones(t *testing.T) {
+	functionUnderTest := "FirstNZones"
+	zones := sets.NewString("us-east-1c", "us-east-1a", "us-west-1a", "us-east-1b")
+	tests := []struct {
+		n    int
+		want []string
+	}{
+		{-1, []string{}},
+		{0, []string{}},
+		{1, []string{"us-east-1a"}},
+		{2, []string{"us-east-1a", "us-east-1b"}},
+		{4, []string{"us-east-1a", "us-east-1b", "us-east-1c", "us-west-1a"}},
+		{5, []string{"us-east-1a", "us-east-1b", "us-east-1c", "us-west-1a"}},
+	}
+	for _, test := range tests {
+		if got := FirstNZones(zones, test.n); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v(%v, %v) returned %v, want %v", functionUnderTest, zones.List(), test.n, got, test.want)
+		}
+	}
+}
+
+func TestCapacityRoundsEvenly(t *testing.T) {
+	functionUnderTest := "CapacityRoundsEvenly"
+	gi := resource.MustParse("1Gi")
+	tests := []struct {
+		capacity string
+		want     bool
+	}{
+		{"2Gi", true},
+		{"1Gi", true},
+		{"1500Mi", false},
+	}
+	for _, test := range tests {
+		pv := &v1.PersistentVolume{
+			Spec: v1.PersistentVolumeSpec{
+				Capacity: v1.ResourceList{v1.ResourceStorage: resource.MustParse(test.capacity)},
+			},
+		}
+		if got := CapacityRoundsEvenly(pv, gi.Value()); got != test.want {
+			t.Errorf("%v(%v, %v) returned %v, want %v", functionUnderTest, test.capacity, gi.Value(), got, test.want)
+		}
+	}
+}
+
+func TestTotalAllocationUnits(t *testing.T) {
+	functionUnderTest := "TotalAllocationUnits"
+	gi := resource.MustParse("1Gi")
+	newPVC := func(name, storage string) *v1.PersistentVolumeClaim {
+		pvc := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "foo"}}
+		if storage != "" {
+			pvc.Spec.Resources.Requests = v1.ResourceList{v1.ResourceStorage: resource.MustParse(storage)}
+		}
+		return pvc
+	}
+	// 1500Mi, 2Gi and 100Mi are rounded up to 2, 2 and 1 GiB
+	pvcs := []*v1.PersistentVolumeClaim{newPVC("a", "1500Mi"), newPVC("b", "2Gi"), newPVC("c", "100Mi")}
+	if got, err := TotalAllocationUnits(pvcs, gi.Value()); err != nil || got != 5 {
+		t.Errorf("%v(1500Mi, 2Gi, 100Mi, %v) returned (%v, %v), want (%v, %v)", functionUnderTest, gi.Value(), got, err, 5, nil)
+	}
+	if got, err := TotalAllocationUnits(nil, gi.Value()); err != nil || got != 0 {
+		t.Errorf("%v(no PVCs, %v) returned (%v, %v), want (%v, %v)", functionUnderTest, gi.Value(), got, err, 0, nil)
+	}
+	if got, err := TotalAllocationUnits(append(pvcs, newPVC("d", "")), gi.Value()); err == nil {
+		t.Errorf("%v(PVC without storage request) returned (%v, %v), want an error", functionUnderTest, got, err)
+	}
+	if got, err := TotalAllocationUnits(pvcs, 0); err == nil {
+		t.Errorf("%v(%v) returned (%v, %v), want an error", functionUnderTest, 0, got, err)
+	}
+}
+
+func TestZoneAssignments(t *testing.T) {
+	functionUnderTest := "ZoneAssignments"
+	zones := sets.NewString("us-east-1a", "us-east-1b", "us-east-1c")
+	pvcNames := []string{"data", "logs", "backup-2023", "data-web-0", "data-web-1", "data-web-2", "logs-web-0", "my-data-web-0", "data-my-app-0", "data-my-app-1"}
+	// the assignments must never change, otherwise existing volumes would be looked up in another zone
+	want := map[string]string{
+		"data":        "us-east-1a",
+		"logs":        "us-east-1c",
+		"backup-2023": "us-east-1b",
+		"data-web-0":  "us-east-1a",
+		"data-web-1":  "us-east-1b",
+		"data-web-2":  "us-east-1c",
+		"logs-web-0":  "us-east-1a",
+		// dashed claim template and StatefulSet names
+		"my-data-web-0": "us-east-1a",
+		"data-my-app-0": "us-east-1c",
+		"data-my-app-1": "us-east-1a",
+	}
+	if got := ZoneAssignments(zones, pvcNames); !reflect.DeepEqual(got, want) {
+		t.Errorf("%v(%v, %v) returned %v, want %v", functionUnderTest, zones.List(), pvcNames, got, want)
+	}
+	if got := ZoneAssignments(sets.NewString(), pvcNames); len(got) != 0 {
+		t.Errorf("%v(no zones, %v) returned %v, want no assignments", functionUnderTest, pvcNames, got)
+	}
+}
+
+func TestChooseZoneForVolumeStable(t *testing.T) {
+	functionUnderTest := "ChooseZoneForVolumeStable"
+	zones := sets.NewString("us-east-1a", "us-east-1b", "us-east-1c")
+	grownZones := sets.NewString("us-east-1a", "us-east-1b", "us-east-1c", "us-east-1d")
+	for i := 0; i < 6; i++ {
+		pvcName := fmt.Sprintf("data-web-%d", i)
+		zone, err := ChooseZoneForVolumeStable(zones, pvcName, 3)
+		if err != nil || zone != ChooseZoneForVolume(zones, pvcName) {
+			t.Errorf("%v(%v, %q, %v) returned (%q, %v), want (%q, %v)", functionUnderTest, zones.List(), pvcName, 3, zone, err, ChooseZoneForVolume(zones, pvcName), nil)
+		}
+		// the zone us-east-1d became available, but the known zone count is pinned
+		if grownZone, err := ChooseZoneForVolumeStable(grownZones, pvcName, 3); err != nil || grownZone != zone {
+			t.Errorf("%v(%v, %q, %v) returned (%q, %v), want (%q, %v)", functionUnderTest, grownZones.List(), pvcName, 3, grownZone, err, zone, nil)
+		}
+	}
+
+	for _, knownZoneCount := range []int{0, 4} {
+		if zone, err := ChooseZoneForVolumeStable(zones, "data-web-0", knownZoneCount); err == nil {
+			t.Errorf("%v(%v, %q, %v) returned (%q, %v), want an error", functionUnderTest, zones.List(), "data-web-0", knownZoneCount, zone, err)
+		}
+	}
+}
+
+func TestWouldShareZone(t *testing.T) {
+	functionUnderTest := "WouldShareZone"
+	zones := sets.NewString("us-east-1a", "us-east-1b", "us-east-1c")
+	tests := []struct {
+		pvcNameA, pvcNameB string
+		want               bool
+	}{
+		// two claims of the member web-0 of the StatefulSet web
+		{"data-web-0", "logs-web-0", true},
+		{"data-web-0", "data-web-1", false},
+		{"data", "logs", false},
+	}
+	for _, test := range tests {
+		if got := WouldShareZone(zones, test.pvcNameA, test.pvcNameB); got != test.want {
+			t.Errorf("%v(%v, %q, %q) returned %v, want %v", functionUnderTest, zones.List(), test.pvcNameA, test.pvcNameB, got, test.want)
+		}
+	}
+}
+
+func TestRecyclerPodContainers(t *testing.T) {
+	functionUnderTest := "internalRecycleVolumeByWatchingPodUntilCompletion"
+	tests := []struct {
+		containers []v1.Container
+		wantErr    bool
+	}{
+		{[]v1.Container{{Name: "recycler", Image: "busybox"}}, false},
+		{nil, true},
+		{[]v1.Container{{Name: "recycler", Image: "busybox"}, {Name: "sidecar", Image: "busybox"}}, true},
+	}
+	for _, test := range tests {
+		client := &mockRecyclerClient{
+			events: []watch.Event{
+				newPodEvent(watch.Modified, "podRecyclerContainers", v1.PodSucceeded, ""),
+			},
+		}
+		pod := newRecyclerPod("podRecyclerContainers")
+		pod.Spec.Containers = test.containers
+		_, err := internalRecycleVolumeByWatchingPodUntilCompletion("pv-containers", pod, client)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v(%v containers) returned %v, want error: %v", functionUnderTest, len(test.containers), err, test.wantErr)
+		}
+		if test.wantErr && client.pod != nil {
+			t.Errorf("%v(%v containers) created the recycler pod, want no pod", functionUnderTest, len(test.containers))
+		}
+	}
+}
+
+func TestValidatePVCSelectorOperatorMessage(t *testing.T) {
+	functionUnderTest := "validatePVCSelector"
+	tests := []struct {
+		operator metav1.LabelSelectorOperator
+		want     string
+	}{
+		{"Gt", `operator "Gt" is not permitted in selector.matchExpressions, values of key "failure-domain.beta.kubernetes.io/zone" are names that can't be compared as numbers, use operator "In" or "NotIn" to list the allowed or forbidden values`},
+		{"Lt", `operator "Lt" is not permitted in selector.matchExpressions, values of key "failure-domain.beta.kubernetes.io/zone" are names that can't be compared as numbers, use operator "In" or "NotIn" to list the allowed or forbidden values`},
+		{metav1.LabelSelectorOpExists, `operator "Exists" is not permitted in selector.matchExpressions, every volume has key "failure-domain.beta.kubernetes.io/zone", use operator "In" or "NotIn" to list the allowed or forbidden values`},
+		{"Bogus", `operator "Bogus" is not permitted in selector.matchExpressions`},
+	}
+	for _, test := range tests {
+		pvc := &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+			Spec: v1.PersistentVolumeClaimSpec{
+				Selector: &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{
+							Key:      metav1.LabelZoneFailureDomain,
+							Operator: test.operator,
+							Values:   []string{"1"},
+						},
+					},
+				},
+			},
+		}
+		if _, err := validatePVCSelector(pvc); err == nil || err.Error() != test.want {
+			t.Errorf("%v(operator %q) returned %v, want error %q", functionUnderTest, test.operator, err, test.want)
+		}
+	}
+}
+
+func TestResolveZonesPreferSingleRegion(t *testing.T) {
+	functionUnderTest := "ResolveZones"
+	zoneToRegion := func(zone string) (string, error) {
+		return zone[:len(zone)-1], nil
+	}
+	tests := []struct {
+		selectorZones []string
+		want          []string
+	}{
+		// us-east-1 contributes more candidates than us-west-1
+		{[]string{"us-east-1a", "us-east-1b", "us-west-1a"}, []string{"us-east-1a", "us-east-1b"}},
+		// a tie is broken by the region name
+		{[]string{"us-east-1a", "us-west-1a"}, []string{"us-east-1a"}},
+		{[]string{"us-west-1a", "us-west-1b"}, []string{"us-west-1a", "us-west-1b"}},
+	}
+	for _, test := range tests {
+		z := ZonesConf{
+			PVC: &v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+				Spec: v1.PersistentVolumeClaimSpec{
+					Selector: &metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{
+								Key:      metav1.LabelZoneFailureDomain,
+								Operator: metav1.LabelSelectorOpIn,
+								Values:   test.selectorZones,
+							},
+						},
+					},
+				},
+			},
+			GetAllZones: func() (sets.String, error) {
+				return sets.NewString("us-east-1a", "us-east-1b", "us-west-1a", "us-west-1b"), nil
+			},
+			ZoneToRegion:       zoneToRegion,
+			PreferSingleRegion: true,
+		}
+		if zones, err := z.ResolveZones(); err != nil || !zones.Equal(sets.NewString(test.want...)) {
+			t.Errorf("%v() for %v returned (%v, %v), want (%v, %v)", functionUnderTest, test.selectorZones, zones.List(), err, test.want, nil)
+		}
+	}
+
+	// the func ZoneToRegion is required
+	z := ZonesConf{
+		PVC: &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"}},
+		GetAllZones: func() (sets.String, error) {
+			return sets.NewString("us-east-1a"), nil
+		},
+		PreferSingleRegion: true,
+	}
+	if zones, err := z.ResolveZones(); err == nil {
+		t.Errorf("%v() without ZoneToRegion returned (%v, %v), want an error", functionUnderTest, zones.List(), err)
+	}
+}
+
+func TestStatefulSetHashKey(t *testing.T) {
+	functionUnderTest := "StatefulSetHashKey"
+	tests := []struct {
+		pvcName           string
+		wantKey           string
+		wantIndex         uint32
+		wantIsStatefulSet bool
+	}{
+		{"data-my-app-0", "app", 0, true},
+		{"data-my-app-1", "app", 1, true},
+		{"my-data-web-0", "web", 0, true},
+		{"logs-web-0", "web", 0, true},
+		{"cache-vol-web-0", "web", 0, true},
+		{"volume-3", "volume", 3, true},
+		{"backup-latest", "backup-latest", 0, false},
+		{"volume", "volume", 0, false},
+		{"data-my-app-", "data-my-app-", 0, false},
+	}
+	for _, test := range tests {
+		key, index, isStatefulSet := StatefulSetHashKey(test.pvcName)
+		if key != test.wantKey || index != test.wantIndex || isStatefulSet != test.wantIsStatefulSet {
+			t.Errorf("%v(%q) returned (%q, %v, %v), want (%q, %v, %v)", functionUnderTest, test.pvcName, key, index, isStatefulSet, test.wantKey, test.wantIndex, test.wantIsStatefulSet)
+		}
+	}
+
+	// every claim of a member gets the same key, so ChooseZoneForVolume puts them into the same zone,
+	// even in case the claim template names contain dashes
+	zones := sets.NewString("us-east-1a", "us-east-1b", "us-east-1c", "us-east-1d")
+	claims := []string{"my-data-web-0", "logs-web-0", "cache-vol-web-0"}
+	for _, claim := range claims[1:] {
+		if zone, want := ChooseZoneForVolume(zones, claim), ChooseZoneForVolume(zones, claims[0]); zone != want {
+			t.Errorf("ChooseZoneForVolume(%v, %q) returned %q, want %q of the claim %q of the same member", zones.List(), claim, zone, want, claims[0])
+		}
+	}
+}
+
+func TestGetConfZonesByRegion(t *testing.T) {
+	functionUnderTest := "GetConfZonesByRegion"
+	zoneToRegion := map[string]string{"z1a": "r1", "z1b": "r1", "z2a": "r2", "z2b": "r2", "z3a": "r3"}
+	z := ZonesConf{
+		PVC: &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+			Spec: v1.PersistentVolumeClaimSpec{
+				Selector: &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{
+							Key:      metav1.LabelZoneRegion,
+							Operator: metav1.LabelSelectorOpIn,
+							Values:   []string{"r1", "r2"},
+						},
+						{
+							Key:      metav1.LabelZoneFailureDomain,
+							Operator: metav1.LabelSelectorOpNotIn,
+							Values:   []string{"z2b"},
+						},
+					},
+				},
+			},
+		},
+		GetAllZones: func() (sets.String, error) {
+			return sets.NewString("z1a", "z1b", "z2a", "z2b", "z3a"), nil
+		},
+		ZoneToRegion: func(zone string) (string, error) {
+			return zoneToRegion[zone], nil
+		},
+	}
+	want := map[string]sets.String{
+		"r1": sets.NewString("z1a", "z1b"),
+		"r2": sets.NewString("z2a"),
+	}
+	if got, err := z.GetConfZonesByRegion(); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("%v() returned (%v, %v), want (%v, %v)", functionUnderTest, got, err, want, nil)
+	}
+
+	z.ZoneToRegion = nil
+	if got, err := z.GetConfZonesByRegion(); err == nil {
+		t.Errorf("%v() without ZoneToRegion returned (%v, %v), want an error", functionUnderTest, got, err)
+	}
+}
+
+func TestSanitizeClusterName(t *testing.T) {
+	functionUnderTest := "SanitizeClusterName"
+	tests := []struct {
+		clusterName string
+		want        string
+	}{
+		{"My_Cluster.01", "my-cluster-01"},
+		{"cluster", "cluster"},
+		{"my--cluster", "my-cluster"},
+		{"_cluster_", "cluster"},
+		{"", DefaultClusterName},
+		{"_.__", DefaultClusterName},
+	}
+	for _, test := range tests {
+		if got := SanitizeClusterName(test.clusterName); got != test.want {
+			t.Errorf("%v(%q) returned %q, want %q", functionUnderTest, test.clusterName, got, test.want)
+		}
+	}
+	if got := GenerateVolumeName("My_Cluster.01", "pv", 255); got != "my-cluster-01-dynamic-pv" {
+		t.Errorf("GenerateVolumeName(%q, %q, 255) returned %q, want %q", "My_Cluster.01", "pv", got, "my-cluster-01-dynamic-pv")
+	}
+	if got := GenerateVolumeName("__", "pv", 255); got != "kubernetes-dynamic-pv" {
+		t.Errorf("GenerateVolumeName(%q, %q, 255) returned %q, want %q", "__", "pv", got, "kubernetes-dynamic-pv")
+	}
+}
+
+func TestRemainingRecycleTimeout(t *testing.T) {
+	functionUnderTest := "remainingRecycleTimeout"
+	pv := &v1.PersistentVolume{
+		Spec: v1.PersistentVolumeSpec{
+			Capacity: v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")},
+		},
+	}
+	// the timeout of a 10Gi volume is 10 * 30 seconds
+	startedAt := time.Date(2017, time.October, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		now  time.Time
+		want time.Duration
+	}{
+		{startedAt, 300 * time.Second},
+		{startedAt.Add(100 * time.Second), 200 * time.Second},
+		{startedAt.Add(300 * time.Second), 0},
+		{startedAt.Add(time.Hour), 0},
+	}
+	for _, test := range tests {
+		if got := remainingRecycleTimeout(startedAt, test.now, pv, 60, 30); got != test.want {
+			t.Errorf("%v(%v, %v) returned %v, want %v", functionUnderTest, startedAt, test.now, got, test.want)
+		}
+	}
+
+	if got := RemainingRecycleTimeout(time.Now(), pv, 60, 30); got <= 299*time.Second || got > 300*time.Second {
+		t.Errorf("RemainingRecycleTimeout(now) returned %v, want about %v", got, 300*time.Second)
+	}
+}
+
+func TestRecyclerDeadline(t *testing.T) {
+	functionUnderTest := "RecyclerDeadline"
+	pv := &v1.PersistentVolume{
+		Spec: v1.PersistentVolumeSpec{
+			Capacity: v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")},
+		},
+	}
+	startedAt := time.Date(2017, time.October, 1, 12, 0, 0, 0, time.UTC)
+	seconds := CalculateTimeoutForVolume(60, 30, pv)
+	want := startedAt.Add(time.Duration(seconds) * time.Second)
+	if got := RecyclerDeadline(startedAt, pv, 60, 30); !got.Time.Equal(want) {
+		t.Errorf("%v(%v) returned %v, want %v", functionUnderTest, startedAt, got, want)
+	}
+}
+
+func TestResolveZonesExcludedZones(t *testing.T) {
+	functionUnderTest := "ResolveZones"
+	newZonesConf := func(excludedZones sets.String, selectorZones ...string) *ZonesConf {
+		return &ZonesConf{
+			PVC: &v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+				Spec: v1.PersistentVolumeClaimSpec{
+					Selector: &metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{
+								Key:      metav1.LabelZoneFailureDomain,
+								Operator: metav1.LabelSelectorOpIn,
+								Values:   selectorZones,
+							},
+						},
+					},
+				},
+			},
+			GetAllZones: func() (sets.String, error) {
+				return sets.NewString("us-east-1a", "us-east-1b", "us-east-1c"), nil
+			},
+			ExcludedZones: excludedZones,
+		}
+	}
+
+	z := newZonesConf(sets.NewString("us-east-1b"), "us-east-1a", "us-east-1b")
+	if zones, err := z.ResolveZones(); err != nil || !zones.Equal(sets.NewString("us-east-1a")) {
+		t.Errorf("%v() returned (%v, %v), want (%v, %v)", functionUnderTest, zones.List(), err, []string{"us-east-1a"}, nil)
+	}
+
+	// the only zone that satisfies the selector is excluded
+	z = newZonesConf(sets.NewString("us-east-1b"), "us-east-1b")
+	if zones, err := z.ResolveZones(); !stderrors.Is(err, ErrNoSatisfyingZone) {
+		t.Errorf("%v() returned (%v, %v), want (%v, %v)", functionUnderTest, zones.List(), err, nil, ErrNoSatisfyingZone)
+	}
+
+	// the excluded zones apply to an empty selector too
+	z = newZonesConf(sets.NewString("us-east-1a", "us-east-1c"))
+	z.PVC.Spec.Selector = nil
+	if zones, err := z.ResolveZones(); err != nil || !zones.Equal(sets.NewString("us-east-1b")) {
+		t.Errorf("%v() returned (%v, %v), want (%v, %v)", functionUnderTest, zones.List(), err, []string{"us-east-1b"}, nil)
+	}
+}
+
+func TestZoneBalancer(t *testing.T) {
+	zones := sets.NewString("us-east-1c", "us-east-1a", "us-east-1b")
+	b := NewZoneBalancer()
+	if zone := b.Next(sets.NewString()); zone != "" {
+		t.Errorf("Next(no zones) returned %q, want %q", zone, "")
+	}
+
+	placed := make([]string, 7)
+	for i := range placed {
+		placed[i] = b.Next(zones)
+		b.Record(placed[i])
+	}
+	want := []string{"us-east-1a", "us-east-1b", "us-east-1c", "us-east-1a", "us-east-1b", "us-east-1c", "us-east-1a"}
+	if !reflect.DeepEqual(placed, want) {
+		t.Errorf("ZoneBalancer placed volumes in %v, want %v", placed, want)
+	}
+
+	// a zone that becomes available later gets the volumes until it catches up
+	zones.Insert("us-east-1d")
+	for i := 0; i < 2; i++ {
+		zone := b.Next(zones)
+		if zone != "us-east-1d" {
+			t.Errorf("Next(%v) returned %q, want %q", zones.List(), zone, "us-east-1d")
+		}
+		b.Record(zone)
+	}
+}
+
+func TestGetConfZonesRegionWithoutZoneToRegion(t *testing.T) {
+	z := ZonesConf{
+		PVC: &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+			Spec: v1.PersistentVolumeClaimSpec{
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{metav1.LabelZoneRegion: "us-east-1"},
+				},
+			},
+		},
+		GetAllZones: func() (sets.String, error) {
+			return sets.NewString("us-east-1a", "us-east-1b"), nil
+		},
+	}
+	want := `selector of PVC "pvc" uses region labels but no ZoneToRegion mapping was provided in ZonesConf`
+	if zones, err := z.ResolveZones(); err == nil || err.Error() != want {
+		t.Errorf("ResolveZones() returned (%v, %v), want (%v, %q)", zones.List(), err, nil, want)
+	}
+	// ZonesInRegion doesn't validate the ZonesConf, but it must not panic either
+	if zones, err := z.ZonesInRegion("us-east-1"); err == nil {
+		t.Errorf("ZonesInRegion(%q) returned (%v, %v), want an error", "us-east-1", zones.List(), err)
+	}
+}
+
+func TestValidateZoneConfig(t *testing.T) {
+	functionUnderTest := "ValidateZoneConfig"
+	validPVC := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{metav1.LabelZoneFailureDomain: "us-east-1a"},
+			},
+		},
+	}
+	invalidPVC := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "db"},
+			},
+		},
+	}
+	tests := []struct {
+		scZone, scZones string
+		pvc             *v1.PersistentVolumeClaim
+		wantErr         bool
+	}{
+		{"", "us-east-1a,us-east-1b", validPVC, false},
+		{"us-east-1a", "", validPVC, false},
+		{"", "", validPVC, false},
+		// a bad selector with valid StorageClass parameters
+		{"", "us-east-1a,us-east-1b", invalidPVC, true},
+		// bad StorageClass parameters with a valid selector
+		{"us-east-1a", "us-east-1b", validPVC, true},
+		{"", "us-east-1a,,us-east-1b", validPVC, true},
+		{"us-east-1a,us-east-1b", "", validPVC, true},
+		{"", "", nil, true},
+	}
+	for _, test := range tests {
+		if err := ValidateZoneConfig(test.scZone, test.scZones, test.pvc); (err != nil) != test.wantErr {
+			t.Errorf("%v(%q, %q, %v) returned %v, want error: %v", functionUnderTest, test.scZone, test.scZones, test.pvc, err, test.wantErr)
+		}
+	}
+	if err := ValidateZoneConfig("us-east-1a", "us-east-1b", validPVC); err != ErrZoneAndZones {
+		t.Errorf("%v(%q, %q, %v) returned %v, want %v", functionUnderTest, "us-east-1a", "us-east-1b", validPVC, err, ErrZoneAndZones)
+	}
+}
+
+func TestChooseZoneForVolumeHealthy(t *testing.T) {
+	functionUnderTest := "ChooseZoneForVolumeHealthy"
+	zones := sets.NewString("us-east-1a", "us-east-1b", "us-east-1c")
+	unhealthy := sets.NewString("us-east-1a", "us-east-1c")
+	for _, pvcName := range []string{"data", "logs", "data-web-0", "data-web-1", "data-web-2"} {
+		if zone, err := ChooseZoneForVolumeHealthy(zones, unhealthy, pvcName); err != nil || zone != "us-east-1b" {
+			t.Errorf("%v(%v, %v, %q) returned (%q, %v), want (%q, %v)", functionUnderTest, zones.List(), unhealthy.List(), pvcName, zone, err, "us-east-1b", nil)
+		}
+	}
+	if zone, err := ChooseZoneForVolumeHealthy(zones, sets.NewString(), "data"); err != nil || zone != ChooseZoneForVolume(zones, "data") {
+		t.Errorf("%v(%v, no unhealthy zones, %q) returned (%q, %v), want (%q, %v)", functionUnderTest, zones.List(), "data", zone, err, ChooseZoneForVolume(zones, "data"), nil)
+	}
+	if zone, err := ChooseZoneForVolumeHealthy(zones, zones, "data"); err == nil {
+		t.Errorf("%v(%v, %v, %q) returned (%q, %v), want an error", functionUnderTest, zones.List(), zones.List(), "data", zone, err)
+	}
+}
+
+func TestChooseZoneForVolumeWithPriority(t *testing.T) {
+	functionUnderTest := "ChooseZoneForVolumeWithPriority"
+	zones := sets.NewString("us-east-1a", "us-east-1b", "us-east-1c")
+	priority := []string{"us-west-2a", "us-east-1c", "us-east-1a"}
+	for _, pvcName := range []string{"data", "logs", "data-web-0", "data-web-1", "data-web-2"} {
+		if zone := ChooseZoneForVolumeWithPriority(zones, pvcName, priority); zone != "us-east-1c" {
+			t.Errorf("%v(%v, %q, %q) returned %q, want %q", functionUnderTest, zones.List(), pvcName, priority, zone, "us-east-1c")
+		}
+	}
+
+	unavailable := []string{"us-west-2a", "us-west-2b"}
+	for _, pvcName := range []string{"data", "logs", "data-web-0", "data-web-1", "data-web-2"} {
+		if zone := ChooseZoneForVolumeWithPriority(zones, pvcName, unavailable); zone != ChooseZoneForVolume(zones, pvcName) {
+			t.Errorf("%v(%v, %q, %q) returned %q, want %q", functionUnderTest, zones.List(), pvcName, unavailable, zone, ChooseZoneForVolume(zones, pvcName))
+		}
+	}
+}
+
+func TestChooseTwoZonesSameRegion(t *testing.T) {
+	functionUnderTest := "ChooseTwoZonesSameRegion"
+	zonesByRegion := map[string]sets.String{
+		"europe-west1": sets.NewString("europe-west1-b"),
+		"us-central1":  sets.NewString("us-central1-a", "us-central1-b"),
+		"us-east1":     sets.NewString("us-east1-c"),
+	}
+	expected := sets.NewString("us-central1-a", "us-central1-b")
+	for _, pvcName := range []string{"data", "logs", "data-web-0", "data-web-1", "data-web-2"} {
+		if zones, err := ChooseTwoZonesSameRegion(zonesByRegion, pvcName); err != nil || !zones.Equal(expected) {
+			t.Errorf("%v(%v, %q) returned (%v, %v), want (%v, %v)", functionUnderTest, zonesByRegion, pvcName, zones, err, expected.List(), nil)
+		}
+	}
+
+	singleZoneRegions := map[string]sets.String{
+		"europe-west1": sets.NewString("europe-west1-b"),
+		"us-east1":     sets.NewString("us-east1-c"),
+	}
+	if zones, err := ChooseTwoZonesSameRegion(singleZoneRegions, "data"); err == nil {
+		t.Errorf("%v(%v, %q) returned (%v, %v), want an error", functionUnderTest, singleZoneRegions, "data", zones, err)
+	}
+}
+
+func TestGetConfZonesLimited(t *testing.T) {
+	functionUnderTest := "GetConfZonesLimited"
+	newZonesConf := func() *ZonesConf {
+		return &ZonesConf{
+			PVC: &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"}},
+			GetAllZones: func() (sets.String, error) {
+				return sets.NewString("us-east-1e", "us-east-1c", "us-east-1a", "us-east-1d", "us-east-1b"), nil
+			},
+		}
+	}
+	expected := sets.NewString("us-east-1a", "us-east-1b")
+	for i := 0; i < 3; i++ {
+		if zones, err := newZonesConf().GetConfZonesLimited(2); err != nil || !zones.Equal(expected) {
+			t.Errorf("%v(%v) returned (%v, %v), want (%v, %v)", functionUnderTest, 2, zones, err, expected.List(), nil)
+		}
+	}
+	if zones, err := newZonesConf().GetConfZonesLimited(10); err != nil || len(zones) != 5 {
+		t.Errorf("%v(%v) returned (%v, %v), want all 5 zones", functionUnderTest, 10, zones, err)
+	}
+	if zones, err := newZonesConf().GetConfZonesLimited(0); err == nil {
+		t.Errorf("%v(%v) returned (%v, %v), want an error", functionUnderTest, 0, zones, err)
+	}
+
+	z := newZonesConf()
+	z.PVC.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{metav1.LabelZoneFailureDomain: "us-west-2a"}}
+	if zones, err := z.GetConfZonesLimited(2); !stderrors.Is(err, ErrNoSatisfyingZone) {
+		t.Errorf("%v(%v) returned (%v, %v), want (%v, %v)", functionUnderTest, 2, zones, err, nil, ErrNoSatisfyingZone)
+	}
+}
+
+func TestGetConfZonesResult(t *testing.T) {
+	functionUnderTest := "GetConfZonesResult"
+	getAllZonesCalls := 0
+	zoneToRegion := map[string]string{"z1a": "r1", "z1b": "r1", "z2a": "r2"}
+	newZonesConf := func() *ZonesConf {
+		return &ZonesConf{
+			PVC: &v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+				Spec: v1.PersistentVolumeClaimSpec{
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{metav1.LabelZoneRegion: "r1"},
+					},
+				},
+			},
+			GetAllZones: func() (sets.String, error) {
+				getAllZonesCalls++
+				return sets.NewString("z1a", "z1b", "z2a"), nil
+			},
+			ZoneToRegion: func(zone string) (string, error) {
+				return zoneToRegion[zone], nil
+			},
+		}
+	}
+
+	result, err := newZonesConf().GetConfZonesResult()
+	if err != nil {
+		t.Fatalf("%v() returned unexpected error: %v", functionUnderTest, err)
+	}
+	if getAllZonesCalls != 1 {
+		t.Errorf("%v() called GetAllZones %v times, want 1", functionUnderTest, getAllZonesCalls)
+	}
+	z := newZonesConf()
+	if zones, err := z.ResolveZones(); err != nil || !result.Zones.Equal(zones) {
+		t.Errorf("%v() returned zones %v, ResolveZones() returned (%v, %v)", functionUnderTest, result.Zones.List(), zones.List(), err)
+	}
+	for _, region := range []string{"r1", "r2"} {
+		if zones, err := z.ZonesInRegion(region); err != nil || !result.RegionToZones[region].Equal(zones) {
+			t.Errorf("%v() returned zones %v in region %q, ZonesInRegion(%q) returned (%v, %v)", functionUnderTest, result.RegionToZones[region].List(), region, region, zones.List(), err)
+		}
+	}
+	if want := sets.NewString("z1a", "z1b", "z2a"); !result.AllAvailable.Equal(want) {
+		t.Errorf("%v() returned all available zones %v, want %v", functionUnderTest, result.AllAvailable.List(), want.List())
+	}
+}
+
+func TestZonesConfForPVC(t *testing.T) {
+	functionUnderTest := "ForPVC"
+	getAllZonesCalls, zoneToRegionCalls := 0, 0
+	newPVC := func(region string) *v1.PersistentVolumeClaim {
+		return &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc-" + region, Namespace: "foo"},
+			Spec: v1.PersistentVolumeClaimSpec{
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{metav1.LabelZoneRegion: region},
+				},
+			},
+		}
+	}
+	z := &ZonesConf{
+		PVC: newPVC("r1"),
+		GetAllZones: func() (sets.String, error) {
+			getAllZonesCalls++
+			return sets.NewString("r1a", "r1b", "r2a", "r2b"), nil
+		},
+		ZoneToRegion: func(zone string) (string, error) {
+			zoneToRegionCalls++
+			return zone[:2], nil
+		},
+	}
+	if err := z.SetZones("r1a,r2a,r2b"); err != nil {
+		t.Fatalf("SetZones() returned unexpected error: %v", err)
+	}
+	if zones, err := z.ResolveZones(); err != nil || !zones.Equal(sets.NewString("r1a")) {
+		t.Errorf("ResolveZones() returned (%v, %v), want (%v, %v)", zones.List(), err, []string{"r1a"}, nil)
+	}
+
+	tests := []struct {
+		region string
+		want   sets.String
+	}{
+		{"r2", sets.NewString("r2a", "r2b")},
+		{"r1", sets.NewString("r1a")},
+	}
+	for _, test := range tests {
+		if zones, err := z.ForPVC(newPVC(test.region)).ResolveZones(); err != nil || !zones.Equal(test.want) {
+			t.Errorf("%v(region %q).ResolveZones() returned (%v, %v), want (%v, %v)", functionUnderTest, test.region, zones.List(), err, test.want.List(), nil)
+		}
+	}
+	// the region map is calculated once for all 4 zones
+	if getAllZonesCalls != 1 || zoneToRegionCalls != 4 {
+		t.Errorf("%v() called GetAllZones %v times and ZoneToRegion %v times, want 1 and 4 times", functionUnderTest, getAllZonesCalls, zoneToRegionCalls)
+	}
+}
+
+func TestAppliedConstraints(t *testing.T) {
+	functionUnderTest := "AppliedConstraints"
+	z := ZonesConf{
+		PVC: &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+			Spec: v1.PersistentVolumeClaimSpec{
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{metav1.LabelZoneRegion: "r1"},
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{
+							Key:      metav1.LabelZoneFailureDomain,
+							Operator: metav1.LabelSelectorOpNotIn,
+							Values:   []string{"r1b"},
+						},
+						// doesn't narrow the set of zones, because r2 was already excluded by the matchLabels
+						{
+							Key:      metav1.LabelZoneRegion,
+							Operator: metav1.LabelSelectorOpNotIn,
+							Values:   []string{"r2"},
+						},
+					},
+				},
+			},
+		},
+		GetAllZones: func() (sets.String, error) {
+			return sets.NewString("r1a", "r1b", "r2a"), nil
+		},
+		ZoneToRegion: func(zone string) (string, error) {
+			return zone[:2], nil
+		},
+	}
+	if zones, err := z.ResolveZones(); err != nil || !zones.Equal(sets.NewString("r1a")) {
+		t.Fatalf("ResolveZones() returned (%v, %v), want (%v, %v)", zones.List(), err, []string{"r1a"}, nil)
+	}
+	want := []string{"matchLabels region=r1", "matchExpressions zone NotIn [r1b]"}
+	if got := z.AppliedConstraints(); !reflect.DeepEqual(got, want) {
+		t.Errorf("%v() returned %q, want %q", functionUnderTest, got, want)
+	}
+}
+
+func TestRoundUpSizeWithWaste(t *testing.T) {
+	functionUnderTest := "RoundUpSizeWithWaste"
+	const mi, gi = int64(1024 * 1024), int64(1024 * 1024 * 1024)
+	tests := []struct {
+		size, unit int64
+		wantUnits  int64
+		wantWaste  int64
+	}{
+		{1500 * mi, gi, 2, 2*gi - 1500*mi},
+		{2 * gi, gi, 2, 0},
+		{1, gi, 1, gi - 1},
+	}
+	for _, test := range tests {
+		if units, waste := RoundUpSizeWithWaste(test.size, test.unit); units != test.wantUnits || waste != test.wantWaste {
+			t.Errorf("%v(%v, %v) returned (%v, %v), want (%v, %v)", functionUnderTest, test.size, test.unit, units, waste, test.wantUnits, test.wantWaste)
+		}
+	}
+}
+
+func TestZonesConfChooseZone(t *testing.T) {
+	functionUnderTest := "ChooseZone"
+	newZonesConf := func() *ZonesConf {
+		return &ZonesConf{
+			PVC: &v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "foo"},
+				Spec: v1.PersistentVolumeClaimSpec{
+					Selector: &metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{
+								Key:      metav1.LabelZoneFailureDomain,
+								Operator: metav1.LabelSelectorOpNotIn,
+								Values:   []string{"us-east-1a"},
+							},
+						},
+					},
+				},
+			},
+			GetAllZones: func() (sets.String, error) {
+				return sets.NewString("us-east-1a", "us-east-1b", "us-east-1c"), nil
+			},
+		}
+	}
+	narrowed := sets.NewString("us-east-1b", "us-east-1c")
+	for _, pvcName := range []string{"data", "logs", "data-web-0", "data-web-1", "data-web-2"} {
+		zone, err := newZonesConf().ChooseZone(pvcName)
+		if err != nil || zone != ChooseZoneForVolume(narrowed, pvcName) {
+			t.Errorf("%v(%q) returned (%q, %v), want (%q, %v)", functionUnderTest, pvcName, zone, err, ChooseZoneForVolume(narrowed, pvcName), nil)
+		}
+		if again, err := newZonesConf().ChooseZone(pvcName); err != nil || again != zone {
+			t.Errorf("%v(%q) returned (%q, %v), want the same zone (%q, %v)", functionUnderTest, pvcName, again, err, zone, nil)
+		}
+	}
+
+	z := newZonesConf()
+	z.PVC.Spec.Selector.MatchExpressions[0].Values = []string{"us-east-1a", "us-east-1b", "us-east-1c"}
+	if zone, err := z.ChooseZone("data"); !stderrors.Is(err, ErrNoSatisfyingZone) {
+		t.Errorf("%v(%q) returned (%q, %v), want (%q, %v)", functionUnderTest, "data", zone, err, "", ErrNoSatisfyingZone)
+	}
+}